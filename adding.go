@@ -1,7 +1,9 @@
 package wts
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 )
 
 func AddActor[MsgType any](node *Node, a Actor[MsgType]) error {
@@ -29,9 +31,9 @@ func AddActor[MsgType any](node *Node, a Actor[MsgType]) error {
 	return nil
 }
 
-func AddEmitter[MsgType any](node *Node, e Emitter[MsgType]) error {
-	proxy := newEmitterProxy(e)
+func AddEmitter[MsgType any](node *Node, e Emitter[MsgType], options ...EmitterOption) error {
 	emitterURL := node.baseURL + "/" + e.Name()
+	proxy := newEmitterProxy(e, emitterURL, options)
 
 	node.emittersMu.RLock()
 	_, exists := node.emitters[emitterURL]
@@ -41,160 +43,131 @@ func AddEmitter[MsgType any](node *Node, e Emitter[MsgType]) error {
 		return errors.New("emitter already exists")
 	}
 
-	node.emittersMu.Lock()
-	node.emitters[emitterURL] = proxy
-	node.emittersMu.Unlock()
+	eventURL := emitterURL + "/" + string(Data)
+	var sequence uint64
 
-	go func(node *Node, emitterURL string, ch <-chan MsgType) {
-		for msg := range ch {
-			err := node.Broadcast(emitterURL+"/"+string(Data), msg)
-			if err != nil {
-				log.Err(err).
-					Str("emitterURL", emitterURL).
-					Msg("could not broadcast data event")
-			}
-		}
-	}(node, emitterURL, e.DataEvents())
+	var batcher *eventBatcher
+	if proxy.options.batch {
+		batcher = newEventBatcher(node, eventURL, proxy.encoderProxy, proxy.options)
+	}
 
-	return nil
-}
+	publish := func(msg MsgType) {
+		payload, err := node.newPayload(context.Background(), eventURL, msg)
+		if err != nil {
+			log.Err(err).
+				Str("emitterURL", emitterURL).
+				Msg("could not create data event")
+			return
+		}
 
-type OnEventFunc[MsgType any] func(eventURL string, msg *EventPayload[MsgType])
-type eventHook struct {
-	*encoderProxy
-	happened func(eventURL string, msg *EventPayload[any]) error
-}
+		payload.Sequence = atomic.AddUint64(&sequence, 1)
 
-func AddEmitterHook[MsgType any](
-	node *Node,
-	actorURL string,
-	onData OnEventFunc[MsgType],
-) (broadcastData func(msg MsgType) error, err error) {
-	encoder := NewEncoderProxy[MsgType]()
+		if proxy.options.ttl > 0 {
+			expires := payload.DateSent.Add(proxy.options.ttl)
+			payload.Expires = &expires
+		}
 
-	if onData == nil {
-		// Add dummy event to keep the encoder for sending messages
-		onData = func(eventURL string, msg *EventPayload[MsgType]) {}
-	}
+		if proxy.options.retain {
+			proxy.retain(payload)
+		}
 
-	if onData != nil {
-		hook := newEventHook(onData)
-		hook.encoderProxy = encoder
+		if batcher != nil {
+			err = batcher.add(payload)
+		} else {
+			err = node.broadcastPayload(eventURL, payload)
+		}
 
-		node.hooksMu.RLock()
-		_, ok := node.hooks[actorURL]
-		node.hooksMu.RUnlock()
+		if err != nil {
+			log.Err(err).
+				Str("emitterURL", emitterURL).
+				Msg("could not broadcast data event")
+		}
+	}
 
-		node.hooksMu.Lock()
-		if !ok {
-			node.hooks[actorURL] = map[EventType]*eventHook{
-				Data: hook,
-			}
-		} else {
-			node.hooks[actorURL][Data] = hook
+	proxy.forward = func(ctx context.Context) {
+		if batcher != nil {
+			defer batcher.flushLogged()
 		}
-		node.hooksMu.Unlock()
 
-		if node.subscribed {
-			err := node.subscribeTopic(actorURL + "/" + string(Data))
-			if err != nil {
-				return nil, err
+		ch := e.DataEvents()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					proxy.finished(ctx, nil)
+					return
+				}
+
+				proxy.emitted()
+				publish(msg)
 			}
 		}
 	}
 
-	return func(msg MsgType) error {
-		return node.Broadcast(actorURL+"/"+string(Data), msg)
-	}, nil
-}
+	node.emittersMu.Lock()
+	node.emitters[emitterURL] = proxy
+	node.emittersMu.Unlock()
 
-func AddActorHook[MsgType any](
-	node *Node,
-	actorURL string,
-	onRequested func(eventURL string, msg *EventPayload[MsgType]),
-	onExecuted func(eventURL string, msg *EventPayload[MsgType]),
-) (broadcastRequest func(msg MsgType) error, err error) {
-	encoder := NewEncoderProxy[MsgType]()
-
-	if onRequested == nil && onExecuted == nil {
-		// add a fake hook so the event url is registered
-		// and has a decoder the node has access to
-		onRequested = func(eventURL string, msg *EventPayload[MsgType]) {}
+	if r, ok := e.(ErrorReporter); ok && r.Errors() != nil {
+		go proxy.reportErrors(r.Errors())
 	}
 
-	if onExecuted != nil {
-		hook := newEventHook(onExecuted)
-		hook.encoderProxy = encoder
+	proxy.start()
+	return nil
+}
 
-		node.hooksMu.RLock()
-		_, ok := node.hooks[actorURL]
-		node.hooksMu.RUnlock()
+type OnEventFunc[MsgType any] func(eventURL string, msg *EventPayload[MsgType])
 
-		node.hooksMu.Lock()
-		if !ok {
-			node.hooks[actorURL] = map[EventType]*eventHook{
-				Executed: hook,
-			}
-		} else {
-			node.hooks[actorURL][Executed] = hook
-		}
-		node.hooksMu.Unlock()
+// AddEmitterHook adds a hook for the data events of an emitter on any node.
+//
+// The returned handle broadcasts data events on behalf of the emitter, and
+// removes the hook. If onData is nil, nothing is subscribed to, and the handle
+// is only used for broadcasting.
+func AddEmitterHook[MsgType any](
+	node *Node,
+	entityURL string,
+	onData OnEventFunc[MsgType],
+	options ...HookOption,
+) (*Hook[MsgType], error) {
+	h := newHook[MsgType](node, entityURL, Data, options)
 
-		if node.subscribed {
-			err := node.subscribeTopic(actorURL + "/" + string(Executed))
-			if err != nil {
-				return nil, err
-			}
-		}
+	err := h.add(Data, onData)
+	if err != nil {
+		return nil, err
 	}
 
-	if onRequested != nil {
-		hook := newEventHook(onRequested)
-		hook.encoderProxy = encoder
-
-		node.hooksMu.RLock()
-		_, ok := node.hooks[actorURL]
-		node.hooksMu.RUnlock()
-
-		node.hooksMu.Lock()
-		if !ok {
-			node.hooks[actorURL] = map[EventType]*eventHook{
-				Request: hook,
-			}
-		} else {
-			node.hooks[actorURL][Request] = hook
-		}
-		node.hooksMu.Unlock()
+	return h, nil
+}
 
-		if node.subscribed {
-			err := node.subscribeTopic(actorURL + "/" + string(Request))
-			if err != nil {
-				return nil, err
-			}
-		}
+// AddActorHook adds a hook for the request and executed events of an actor on any node.
+//
+// The returned handle broadcasts requests to the actor, and removes the hook.
+// If onRequested and onExecuted are nil, nothing is subscribed to, and the handle
+// is only used for broadcasting.
+func AddActorHook[MsgType any](
+	node *Node,
+	entityURL string,
+	onRequested OnEventFunc[MsgType],
+	onExecuted OnEventFunc[MsgType],
+	options ...HookOption,
+) (*Hook[MsgType], error) {
+	h := newHook[MsgType](node, entityURL, Request, options)
+
+	err := h.add(Request, onRequested)
+	if err == nil {
+		err = h.add(Executed, onExecuted)
 	}
 
-	return func(msg MsgType) error {
-		return node.Broadcast(actorURL+"/"+string(Request), msg)
-	}, nil
-}
+	if err == nil && h.options.onExpired != nil {
+		err = h.register(Expired, h.options.onExpired)
+	}
 
-func newEventHook[MsgType any](onEvent OnEventFunc[MsgType]) *eventHook {
-	return &eventHook{
-		encoderProxy: NewEncoderProxy[MsgType](),
-		happened: func(eventURL string, e *EventPayload[any]) error {
-			switch d := e.Data.(type) {
-			case MsgType:
-				onEvent(eventURL, &EventPayload[MsgType]{
-					Data:      d,
-					DateSent:  e.DateSent,
-					Sender:    e.Sender,
-					EventType: e.EventType,
-				})
-				return nil
-			default:
-				return errors.New("incorrect message type provided to event hook")
-			}
-		},
+	if err != nil {
+		h.Remove()
+		return nil, err
 	}
+
+	return h, nil
 }
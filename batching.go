@@ -0,0 +1,168 @@
+package wts
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// eventBatcher coalesces the data events of an emitter into batches.
+type eventBatcher struct {
+	// node the batches are published through
+	node *Node
+	// event URL the batches are published to
+	eventURL string
+	// encodes the events of the batch
+	encoder *encoderProxy
+	// how long events are held for before the batch is published
+	window time.Duration
+	// number of events that causes the batch to be published immediately
+	maxEvents int
+	// encoded events waiting to be published
+	pending []json.RawMessage
+	// publishes the pending events once the window ends, nil if there are none
+	timer *time.Timer
+	// incremented every time the pending events are taken,
+	// so timers of batches that were already published do nothing
+	generation uint64
+	// pending, timer and generation mutex
+	mu *sync.Mutex
+	// held while taking and publishing the pending events,
+	// so batches are published in order
+	publishMu *sync.Mutex
+}
+
+func newEventBatcher(node *Node, eventURL string, encoder *encoderProxy, options *emitterOptions) *eventBatcher {
+	return &eventBatcher{
+		node:      node,
+		eventURL:  eventURL,
+		encoder:   encoder,
+		window:    options.batchWindow,
+		maxEvents: options.batchSize,
+		mu:        &sync.Mutex{},
+		publishMu: &sync.Mutex{},
+	}
+}
+
+// add adds a payload to the batch, and publishes the batch if it is full.
+func (b *eventBatcher) add(payload *EventPayload[any]) error {
+	content, err := b.encoder.Encode(payload)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, content)
+	// without a window or size, every event is its own batch
+	full := b.maxEvents <= 0 && b.window <= 0 ||
+		b.maxEvents > 0 && len(b.pending) >= b.maxEvents
+	if !full && b.timer == nil && b.window > 0 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.window, func() {
+			b.flushWindow(generation)
+		})
+	}
+	b.mu.Unlock()
+
+	if full {
+		return b.flush()
+	}
+
+	return nil
+}
+
+// flush publishes the pending events.
+func (b *eventBatcher) flush() error {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	b.mu.Lock()
+	pending := b.take()
+	b.mu.Unlock()
+
+	return b.publish(pending)
+}
+
+// flushWindow publishes the pending events once the window of a batch ends,
+// unless the batch was already published.
+func (b *eventBatcher) flushWindow(generation uint64) {
+	b.publishMu.Lock()
+	defer b.publishMu.Unlock()
+
+	b.mu.Lock()
+	if b.generation != generation {
+		// published when it filled up, the pending events are of a later batch
+		b.mu.Unlock()
+		return
+	}
+	pending := b.take()
+	b.mu.Unlock()
+
+	err := b.publish(pending)
+	if err != nil {
+		log.Err(err).
+			Str("eventURL", b.eventURL).
+			Msg("could not broadcast batch of data events")
+	}
+}
+
+// take returns the pending events, and starts a new batch.
+// The batcher must be locked.
+func (b *eventBatcher) take() []json.RawMessage {
+	pending := b.pending
+	b.pending = nil
+	b.generation++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	return pending
+}
+
+// publish publishes a batch of events.
+//
+// A single event is published as a regular payload.
+func (b *eventBatcher) publish(pending []json.RawMessage) error {
+	switch len(pending) {
+	case 0:
+		return nil
+	case 1:
+		return b.node.publish(b.eventURL, PayloadContentType, pending[0])
+	}
+
+	content, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	return b.node.publish(b.eventURL, BatchContentType, content)
+}
+
+// flushLogged publishes the pending events, and logs any error.
+func (b *eventBatcher) flushLogged() {
+	err := b.flush()
+	if err != nil {
+		log.Err(err).
+			Str("eventURL", b.eventURL).
+			Msg("could not broadcast batch of data events")
+	}
+}
+
+// handleBatch splits a batch into its events, and handles each of them.
+func (n *Node) handleBatch(topic string, body io.Reader) {
+	var batch []json.RawMessage
+	err := json.NewDecoder(body).Decode(&batch)
+	if err != nil {
+		log.Err(err).
+			Str("topic", topic).
+			Msg("could not decode batch of events")
+		return
+	}
+
+	for _, content := range batch {
+		n.handleEvent(topic, PayloadContentType, bytes.NewReader(content))
+	}
+}
@@ -0,0 +1,38 @@
+package wts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBatcherStaleWindow(t *testing.T) {
+	n := NewNode("http://localhost:9101/", "http://localhost:9100/")
+	b := newEventBatcher(n, "http://localhost:9101/temp/data", NewEncoderProxy[int](), &emitterOptions{
+		batchWindow: time.Hour,
+		batchSize:   2,
+	})
+
+	pendingLen := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.pending)
+	}
+
+	// the first batch starts a window, then is published when it fills up
+	b.add(&EventPayload[any]{Data: 1})
+	first := b.generation
+	b.add(&EventPayload[any]{Data: 2})
+
+	b.add(&EventPayload[any]{Data: 3})
+	second := b.generation
+
+	b.flushWindow(first)
+	if got := pendingLen(); got != 1 {
+		t.Fatalf("the window of a published batch flushed the next one, %d events pending", got)
+	}
+
+	b.flushWindow(second)
+	if got := pendingLen(); got != 0 {
+		t.Fatalf("the window of the pending batch did not flush it, %d events pending", got)
+	}
+}
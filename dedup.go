@@ -0,0 +1,177 @@
+package wts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Default amount of time a received event is remembered for deduplication.
+	defaultDedupWindow = 5 * time.Minute
+	// Default maximum number of events remembered for deduplication.
+	defaultDedupSize = 10000
+	// Minimum time between saves of the seen events file.
+	dedupSaveDelay = time.Second
+)
+
+// WithDedup sets how long, and how many, received events are remembered
+// to drop duplicate deliveries. Defaults to 5 minutes and 10000 events.
+//
+// Deduplication is disabled if window or maxEvents is zero or less.
+func WithDedup(window time.Duration, maxEvents int) NodeOption {
+	return func(n *Node) {
+		n.seen.window = window
+		n.seen.maxEvents = maxEvents
+	}
+}
+
+// WithDedupFile persists the events remembered for deduplication to
+// a file, so duplicates are still dropped after the node restarts.
+func WithDedupFile(path string) NodeOption {
+	return func(n *Node) {
+		n.seen.path = path
+	}
+}
+
+// eventKey returns the key a decoded event is deduplicated by, which is its ID,
+// or a hash of the content and topic for payloads without an ID.
+func eventKey(topic string, envelope *EventPayload[json.RawMessage], content []byte) string {
+	if envelope.ID != "" {
+		return envelope.ID
+	}
+
+	sum := sha256.Sum256(content)
+	return topic + " " + hex.EncodeToString(sum[:])
+}
+
+// seenEvent is an event remembered for deduplication.
+type seenEvent struct {
+	Key  string    `json:"key"`
+	Seen time.Time `json:"seen"`
+}
+
+// seenEvents remembers received events for a window of time, to drop duplicate deliveries.
+type seenEvents struct {
+	// maps event key to when it was first seen
+	seen map[string]time.Time
+	// events in the order they were seen
+	order []seenEvent
+	// how long events are remembered for
+	window time.Duration
+	// how many events are remembered at most
+	maxEvents int
+	// file the events are persisted to, empty to not persist
+	path string
+	// whether a save of the events file is pending
+	savePending bool
+	// seen mutex
+	mu *sync.Mutex
+}
+
+func newSeenEvents() *seenEvents {
+	return &seenEvents{
+		seen:      make(map[string]time.Time),
+		window:    defaultDedupWindow,
+		maxEvents: defaultDedupSize,
+		mu:        &sync.Mutex{},
+	}
+}
+
+// add records an event, and returns whether it had not been seen before.
+// Every event is new if deduplication is disabled.
+func (s *seenEvents) add(key string) (isNew bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.window <= 0 || s.maxEvents <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	s.evict(now)
+
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+
+	s.seen[key] = now
+	s.order = append(s.order, seenEvent{Key: key, Seen: now})
+	s.evict(now)
+
+	if s.path != "" && !s.savePending {
+		s.savePending = true
+		time.AfterFunc(dedupSaveDelay, s.save)
+	}
+
+	return true
+}
+
+// evict forgets events that are too old, or over the maximum number of events.
+func (s *seenEvents) evict(now time.Time) {
+	var i int
+	for i < len(s.order) &&
+		(now.Sub(s.order[i].Seen) > s.window || len(s.order)-i > s.maxEvents) {
+		delete(s.seen, s.order[i].Key)
+		i++
+	}
+
+	s.order = s.order[i:]
+}
+
+// load restores the events from the events file, if it exists.
+func (s *seenEvents) load() error {
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var order []seenEvent
+	err = json.Unmarshal(content, &order)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range order {
+		if _, ok := s.seen[e.Key]; !ok {
+			s.seen[e.Key] = e.Seen
+			s.order = append(s.order, e)
+		}
+	}
+	s.evict(time.Now())
+
+	return nil
+}
+
+// save writes the events to the events file.
+func (s *seenEvents) save() {
+	s.mu.Lock()
+	s.savePending = false
+	content, err := json.Marshal(s.order)
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Err(err).Msg("could not encode seen events")
+		return
+	}
+
+	// write then rename, so a crash never leaves a partial file
+	err = os.WriteFile(s.path+".tmp", content, 0o600)
+	if err == nil {
+		err = os.Rename(s.path+".tmp", s.path)
+	}
+
+	if err != nil {
+		log.Err(err).
+			Str("path", s.path).
+			Msg("could not save seen events")
+	}
+}
@@ -0,0 +1,74 @@
+package wts
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSeenEventsAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    time.Duration
+		maxEvents int
+		keys      []string
+		want      []bool
+	}{
+		{"duplicates", time.Minute, 10, []string{"a", "b", "a", "b"}, []bool{true, true, false, false}},
+		{"over the maximum", time.Minute, 2, []string{"a", "b", "c", "a", "c"}, []bool{true, true, true, true, false}},
+		{"disabled by window", 0, 10, []string{"a", "a"}, []bool{true, true}},
+		{"disabled by maximum", time.Minute, 0, []string{"a", "a"}, []bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSeenEvents()
+			s.window = tt.window
+			s.maxEvents = tt.maxEvents
+
+			for i, key := range tt.keys {
+				if got := s.add(key); got != tt.want[i] {
+					t.Errorf("add(%q) #%d = %v, want %v", key, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSeenEventsWindow(t *testing.T) {
+	s := newSeenEvents()
+	s.window = time.Minute
+
+	s.add("old")
+	s.add("recent")
+
+	// seen before the window
+	s.order[0].Seen = time.Now().Add(-2 * time.Minute)
+	s.seen["old"] = s.order[0].Seen
+
+	if !s.add("old") {
+		t.Error("an event seen before the window is still a duplicate")
+	}
+
+	if s.add("recent") {
+		t.Error("an event seen in the window is not a duplicate")
+	}
+}
+
+func TestEventKey(t *testing.T) {
+	content := []byte(`{"data":1}`)
+
+	withID := &EventPayload[json.RawMessage]{ID: "id"}
+	if got := eventKey("topic", withID, content); got != "id" {
+		t.Errorf("eventKey() = %q, want the event ID", got)
+	}
+
+	withoutID := &EventPayload[json.RawMessage]{}
+	if eventKey("a", withoutID, content) == eventKey("b", withoutID, content) {
+		t.Error("events without an ID on different topics have the same key")
+	}
+
+	if eventKey("a", withoutID, content) != eventKey("a", withoutID, content) {
+		t.Error("the same event without an ID has different keys")
+	}
+}
@@ -1,5 +1,9 @@
 package wts
 
+import (
+	"time"
+)
+
 // An Emitter is a data source for a Node
 // to publish data events about.
 type Emitter[MsgType any] interface {
@@ -32,3 +36,61 @@ func NewBasicEmitter[MsgType any](name string, ch <-chan MsgType) Emitter[MsgTyp
 		DataChannel: ch,
 	})
 }
+
+// EmitterOption configures an emitter added with AddEmitter.
+type EmitterOption func(o *emitterOptions)
+
+// emitterOptions are the options an emitter was added with.
+type emitterOptions struct {
+	// whether the last data event is retained for new subscribers
+	retain bool
+	// how long data events are valid for, zero if forever
+	ttl time.Duration
+	// whether data events are published in batches
+	batch bool
+	// how long data events are held for before a batch is published
+	batchWindow time.Duration
+	// number of data events that causes a batch to be published immediately
+	batchSize int
+}
+
+// EmitterWithRetainedValue retains the emitter's last data event, and serves it
+// to new subscribers, similar to MQTT retained messages.
+//
+// The retained event is served on HTTP GET requests to the data event URL, and
+// nodes fetch it for their hooks once their subscription is verified.
+func EmitterWithRetainedValue() EmitterOption {
+	return func(o *emitterOptions) {
+		o.retain = true
+	}
+}
+
+// EmitterWithTTL sets how long the emitter's data events are valid for.
+// Receiving nodes drop data events that arrive after they expired.
+func EmitterWithTTL(ttl time.Duration) EmitterOption {
+	return func(o *emitterOptions) {
+		o.ttl = ttl
+	}
+}
+
+// EmitterWithBatching publishes the emitter's data events in batches, which are
+// split back into individual data events by receiving nodes.
+//
+// A batch is published once window passed since its first data event, or once
+// it has maxEvents data events. Either may be zero to only use the other.
+func EmitterWithBatching(window time.Duration, maxEvents int) EmitterOption {
+	return func(o *emitterOptions) {
+		o.batch = true
+		o.batchWindow = window
+		o.batchSize = maxEvents
+	}
+}
+
+func newEmitterOptions(options []EmitterOption) *emitterOptions {
+	o := &emitterOptions{}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	return o
+}
@@ -0,0 +1,136 @@
+package wts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/itchyny/gojq"
+)
+
+// FilterStats counts the events a hook's filters were applied to.
+type FilterStats struct {
+	// Events that passed all filters.
+	Passed uint64
+	// Events that were filtered out.
+	Filtered uint64
+	// Events that were filtered out because a filter failed.
+	Errors uint64
+}
+
+// filterStats counts the events a hook's filters were applied to.
+type filterStats struct {
+	passed   uint64
+	filtered uint64
+	errors   uint64
+}
+
+func (s *filterStats) get() FilterStats {
+	return FilterStats{
+		Passed:   atomic.LoadUint64(&s.passed),
+		Filtered: atomic.LoadUint64(&s.filtered),
+		Errors:   atomic.LoadUint64(&s.errors),
+	}
+}
+
+// HookWithFilter sets a predicate that an event must pass
+// before it is passed to the hook's callback.
+//
+// Events of a different type than MsgType never pass.
+func HookWithFilter[MsgType any](pred func(msg *EventPayload[MsgType]) bool) HookOption {
+	return func(o *hookOptions) {
+		o.filter = func(msg any) bool {
+			typed, ok := msg.(*EventPayload[MsgType])
+			return ok && pred(typed)
+		}
+	}
+}
+
+// HookWithJQFilter sets a jq query that an event's data must pass before it is
+// decoded and passed to the hook's callback. Events pass when the first output
+// of the query is neither false nor null.
+//
+// The payload without its data is available as $payload.
+func HookWithJQFilter(query string) HookOption {
+	return func(o *hookOptions) {
+		parsed, err := gojq.Parse(query)
+		if err != nil {
+			o.err = fmt.Errorf("jq filter: %w", err)
+			return
+		}
+
+		code, err := gojq.Compile(parsed, gojq.WithVariables([]string{"$payload"}))
+		if err != nil {
+			o.err = fmt.Errorf("jq filter: %w", err)
+			return
+		}
+
+		o.rawFilter = func(raw *EventPayload[json.RawMessage]) (bool, error) {
+			return runJQFilter(code, raw)
+		}
+	}
+}
+
+// runJQFilter runs a compiled jq filter on the data of the payload.
+func runJQFilter(code *gojq.Code, raw *EventPayload[json.RawMessage]) (bool, error) {
+	var data any
+	err := json.Unmarshal(raw.Data, &data)
+	if err != nil {
+		return false, err
+	}
+
+	// Convert the payload to JSON and back to get it into a state that
+	// gojq will accept
+	var payload map[string]any
+	bytes, err := json.Marshal(withData[json.RawMessage, any](raw, nil))
+	if err != nil {
+		return false, err
+	}
+
+	err = json.Unmarshal(bytes, &payload)
+	if err != nil {
+		return false, err
+	}
+	delete(payload, "data")
+
+	v, ok := code.Run(data, payload).Next()
+	if !ok {
+		return false, nil
+	}
+
+	if err, ok := v.(error); ok {
+		return false, err
+	}
+
+	return v != nil && v != false, nil
+}
+
+// acceptRaw applies the jq filter to an event, and counts it if filtered out.
+func (o *hookOptions) acceptRaw(raw *EventPayload[json.RawMessage]) bool {
+	if o.rawFilter == nil {
+		return true
+	}
+
+	ok, err := o.rawFilter(raw)
+	if err != nil {
+		log.Err(err).Msg("jq filter failed")
+		atomic.AddUint64(&o.stats.errors, 1)
+	}
+
+	if !ok {
+		atomic.AddUint64(&o.stats.filtered, 1)
+	}
+
+	return ok
+}
+
+// accept applies the predicate to an event, and counts it.
+func (o *hookOptions) accept(msg any) bool {
+	if o.filter != nil && !o.filter(msg) {
+		atomic.AddUint64(&o.stats.filtered, 1)
+		return false
+	}
+
+	atomic.AddUint64(&o.stats.passed, 1)
+	return true
+}
@@ -0,0 +1,98 @@
+package wts
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestHookFilterStats(t *testing.T) {
+	even := HookWithFilter(func(msg *EventPayload[float64]) bool {
+		return int(msg.Data)%2 == 0
+	})
+
+	tests := []struct {
+		name      string
+		options   []HookOption
+		want      []float64
+		wantStats FilterStats
+	}{
+		{
+			name:      "no filters",
+			want:      []float64{1, 2, 3, 4},
+			wantStats: FilterStats{Passed: 4},
+		},
+		{
+			name:      "predicate",
+			options:   []HookOption{even},
+			want:      []float64{2, 4},
+			wantStats: FilterStats{Passed: 2, Filtered: 2},
+		},
+		{
+			name:      "jq",
+			options:   []HookOption{HookWithJQFilter(". > 2")},
+			want:      []float64{3, 4},
+			wantStats: FilterStats{Passed: 2, Filtered: 2},
+		},
+		{
+			name:      "jq and predicate",
+			options:   []HookOption{HookWithJQFilter(". > 2"), even},
+			want:      []float64{4},
+			wantStats: FilterStats{Passed: 1, Filtered: 3},
+		},
+		{
+			name:      "jq with payload",
+			options:   []HookOption{HookWithJQFilter(`$payload.eventType == "data" and . == 1`)},
+			want:      []float64{1},
+			wantStats: FilterStats{Passed: 1, Filtered: 3},
+		},
+		{
+			name:      "failing jq",
+			options:   []HookOption{HookWithJQFilter(".foo")},
+			want:      nil,
+			wantStats: FilterStats{Filtered: 4, Errors: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNode("http://localhost:9101/", "http://localhost:9100/")
+
+			var got []float64
+			hook, err := AddEmitterHook(n, "http://localhost:9102/count", func(eventURL string, msg *EventPayload[float64]) {
+				got = append(got, msg.Data)
+			}, tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, data := range []float64{1, 2, 3, 4} {
+				content, err := EncodeMessage(data, Data, "http://localhost:9102/")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				n.handleEvent("http://localhost:9102/count/data", PayloadContentType, bytes.NewReader(content))
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hook got %v, want %v", got, tt.want)
+			}
+
+			if stats := hook.FilterStats(); stats != tt.wantStats {
+				t.Errorf("FilterStats() = %+v, want %+v", stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestHookWithJQFilterInvalid(t *testing.T) {
+	n := NewNode("http://localhost:9101/", "http://localhost:9100/")
+
+	_, err := AddEmitterHook(n, "http://localhost:9102/count", func(string, *EventPayload[float64]) {},
+		HookWithJQFilter(". >"),
+	)
+	if err == nil {
+		t.Error("AddEmitterHook succeeded with an invalid jq filter")
+	}
+}
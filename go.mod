@@ -3,13 +3,13 @@ module github.com/notnotquinn/wts
 go 1.18
 
 require (
+	github.com/google/uuid v1.3.0
 	github.com/itchyny/gojq v0.12.7
 	github.com/notnotquinn/go-websub v0.2.1-0.20220401210256-463b0ef0c0e0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
 
 require (
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/itchyny/timefmt-go v0.1.3 // indirect
 	github.com/rs/zerolog v1.26.1 // indirect
 	github.com/tomnomnom/linkheader v0.0.0-20180905144013-02ca5825eb80 // indirect
@@ -0,0 +1,346 @@
+package wts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Hook receives events of an entity on any node, and broadcasts events on
+// behalf of the entity. Any number of hooks may exist for the same event.
+type Hook[MsgType any] struct {
+	// node the hook was added to
+	node *Node
+	// entity the hook is for
+	entityURL string
+	// type of the events sent with Broadcast
+	broadcastType EventType
+	// encoder used for broadcasting
+	encoder *encoderProxy
+	// options the hook was added with
+	options *hookOptions
+	// event hooks registered on the node by the hook
+	registered map[EventType]*eventHook
+}
+
+func newHook[MsgType any](
+	node *Node,
+	entityURL string,
+	broadcastType EventType,
+	options []HookOption,
+) *Hook[MsgType] {
+	return &Hook[MsgType]{
+		node:          node,
+		entityURL:     strings.TrimRight(entityURL, "/"),
+		broadcastType: broadcastType,
+		encoder:       NewEncoderProxy[MsgType](),
+		options:       newHookOptions(options),
+		registered:    make(map[EventType]*eventHook),
+	}
+}
+
+// EntityURL returns the URL of the entity the hook is for.
+func (h *Hook[MsgType]) EntityURL() string {
+	return h.entityURL
+}
+
+// Broadcast publishes an event on behalf of the entity, which is a data event
+// for emitter hooks and a request for actor hooks.
+func (h *Hook[MsgType]) Broadcast(msg MsgType) error {
+	return h.BroadcastContext(context.Background(), msg)
+}
+
+// BroadcastContext is like Broadcast, but links the event
+// to the event carried by ctx, see ContextWithEvent.
+func (h *Hook[MsgType]) BroadcastContext(ctx context.Context, msg MsgType) error {
+	return h.options.broadcast(ctx, h.node, h.encoder, h.entityURL+"/"+string(h.broadcastType), msg)
+}
+
+// Remove removes the hook from the node, and unsubscribes
+// from events no longer needed by the node.
+func (h *Hook[MsgType]) Remove() error {
+	var lastErr error
+	for eventType, hook := range h.registered {
+		err := h.node.removeEventHook(h.entityURL, eventType, hook)
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	h.registered = make(map[EventType]*eventHook)
+	return lastErr
+}
+
+// FilterStats returns how many events passed the hook's filters,
+// and how many were filtered out.
+func (h *Hook[MsgType]) FilterStats() FilterStats {
+	return h.options.stats.get()
+}
+
+// add registers a callback for an event type, if it is not nil.
+func (h *Hook[MsgType]) add(eventType EventType, onEvent OnEventFunc[MsgType]) error {
+	if h.options.err != nil {
+		return h.options.err
+	}
+
+	if onEvent == nil {
+		return nil
+	}
+
+	return h.register(eventType, newEventHook(onEvent, h.options))
+}
+
+// register adds an event hook to the node.
+func (h *Hook[MsgType]) register(eventType EventType, hook *eventHook) error {
+	h.registered[eventType] = hook
+	return h.node.addEventHook(h.entityURL, eventType, hook)
+}
+
+// eventHook is a single callback for events of a type on an entity.
+type eventHook struct {
+	*encoderProxy
+	// accept returns whether the event should be decoded and passed to happened
+	accept   func(eventURL string, raw *EventPayload[json.RawMessage]) bool
+	happened func(eventURL string, msg *EventPayload[any]) error
+}
+
+func newEventHook[MsgType any](onEvent OnEventFunc[MsgType], opts *hookOptions) *eventHook {
+	gaps := newGapDetector()
+
+	return &eventHook{
+		encoderProxy: NewEncoderProxy[MsgType](),
+		accept: func(eventURL string, raw *EventPayload[json.RawMessage]) bool {
+			// check for gaps before filtering, so filtered events are not missed events
+			if opts.onGap != nil {
+				if gap := gaps.check(raw); gap != nil {
+					opts.onGap(eventURL, *gap)
+				}
+			}
+
+			return opts.acceptRaw(raw)
+		},
+		happened: func(eventURL string, e *EventPayload[any]) error {
+			switch d := e.Data.(type) {
+			case MsgType:
+				msg := withData(e, d)
+				if !opts.accept(msg) {
+					return nil
+				}
+
+				onEvent(eventURL, msg)
+				return nil
+			default:
+				return errors.New("incorrect message type provided to event hook")
+			}
+		},
+	}
+}
+
+// addEventHook adds a hook for an event, and subscribes to the event if needed.
+func (n *Node) addEventHook(entityURL string, eventType EventType, hook *eventHook) error {
+	n.hooksMu.Lock()
+	if _, ok := n.hooks[entityURL]; !ok {
+		n.hooks[entityURL] = make(map[EventType][]*eventHook)
+	}
+	n.hooks[entityURL][eventType] = append(n.hooks[entityURL][eventType], hook)
+	n.hooksMu.Unlock()
+
+	if n.subscribed {
+		return n.subscribeTopic(entityURL + "/" + string(eventType))
+	}
+
+	return nil
+}
+
+// removeEventHook removes a hook for an event, and unsubscribes
+// from the event if nothing else on the node needs it.
+func (n *Node) removeEventHook(entityURL string, eventType EventType, hook *eventHook) error {
+	n.hooksMu.Lock()
+	hooks := n.hooks[entityURL][eventType]
+	for i, h := range hooks {
+		if h == hook {
+			hooks = append(hooks[:i:i], hooks[i+1:]...)
+			break
+		}
+	}
+
+	remaining := len(hooks)
+	if remaining > 0 {
+		n.hooks[entityURL][eventType] = hooks
+	} else {
+		delete(n.hooks[entityURL], eventType)
+		if len(n.hooks[entityURL]) == 0 {
+			delete(n.hooks, entityURL)
+		}
+	}
+	n.hooksMu.Unlock()
+
+	if remaining > 0 || !n.subscribed {
+		return nil
+	}
+
+	if eventType == Request {
+		n.actorsMu.RLock()
+		_, exists := n.actors[entityURL]
+		n.actorsMu.RUnlock()
+
+		if exists {
+			return nil
+		}
+	}
+
+	return n.unsubscribeTopic(entityURL + "/" + string(eventType))
+}
+
+// getEventHooks returns the hooks for an event.
+func (n *Node) getEventHooks(entityURL string, eventType EventType) []*eventHook {
+	n.hooksMu.RLock()
+	defer n.hooksMu.RUnlock()
+
+	hooks := n.hooks[entityURL][eventType]
+	return append([]*eventHook(nil), hooks...)
+}
+
+// HookOption configures a hook added with AddEmitterHook or AddActorHook.
+type HookOption func(o *hookOptions)
+
+// hookOptions are the options a hook was added with.
+type hookOptions struct {
+	// called when events from an emitter were missed
+	onGap OnGapFunc
+	// how long events broadcast through the hook are valid for, zero if forever
+	ttl time.Duration
+	// hook for expired events, nil if there is none
+	onExpired *eventHook
+	// filters events before they are decoded, nil if there is none
+	rawFilter func(raw *EventPayload[json.RawMessage]) (bool, error)
+	// filters events before they are passed to the callback, nil if there is none
+	filter func(msg any) bool
+	// counts of filtered events
+	stats *filterStats
+	// set when an option could not be applied
+	err error
+}
+
+// Gap describes events that were missed from an emitter.
+type Gap struct {
+	// The node that sent the events.
+	Sender string
+	// The boot ID of the sender when the events were sent.
+	BootID string
+	// The sequence number that was expected next.
+	Expected uint64
+	// The sequence number that was received.
+	Got uint64
+}
+
+// Missed returns the number of events that were missed.
+func (g Gap) Missed() uint64 {
+	return g.Got - g.Expected
+}
+
+// OnGapFunc is called when a hook detects it missed events from an emitter.
+type OnGapFunc func(eventURL string, gap Gap)
+
+// HookWithGapHandler sets a function to be called when the hook receives an
+// event with a sequence number higher than the next one expected, which means
+// events were missed, so consumers can resync.
+//
+// Only events sent by emitters have sequence numbers.
+func HookWithGapHandler(onGap OnGapFunc) HookOption {
+	return func(o *hookOptions) {
+		o.onGap = onGap
+	}
+}
+
+// HookWithTTL sets how long events broadcast through the hook are valid for,
+// such as requests sent with the function returned by AddActorHook.
+// Receiving nodes drop events that arrive after they expired.
+func HookWithTTL(ttl time.Duration) HookOption {
+	return func(o *hookOptions) {
+		o.ttl = ttl
+	}
+}
+
+// HookWithOnExpired sets a function to be called when the actor's node reports a
+// request expired before it could be performed. Only used with AddActorHook.
+//
+// Nodes only report expired requests when created with WithExpiredEvents.
+func HookWithOnExpired[MsgType any](onExpired OnEventFunc[MsgType]) HookOption {
+	return func(o *hookOptions) {
+		o.onExpired = newEventHook(onExpired, newHookOptions(nil))
+	}
+}
+
+func newHookOptions(options []HookOption) *hookOptions {
+	o := &hookOptions{stats: &filterStats{}}
+	for _, opt := range options {
+		opt(o)
+	}
+
+	return o
+}
+
+// broadcast publishes an event with the hook's options applied.
+func (o *hookOptions) broadcast(ctx context.Context, node *Node, encoder *encoderProxy, eventURL string, msg any) error {
+	payload, err := node.newPayload(ctx, eventURL, msg)
+	if err != nil {
+		return err
+	}
+
+	if o.ttl > 0 {
+		expires := payload.DateSent.Add(o.ttl)
+		payload.Expires = &expires
+	}
+
+	return node.publishPayload(eventURL, encoder, payload)
+}
+
+// gapDetector detects missed events using sequence numbers.
+type gapDetector struct {
+	// maps sender boot ID to the highest sequence number received
+	last map[string]uint64
+	// last mutex
+	mu *sync.Mutex
+}
+
+func newGapDetector() *gapDetector {
+	return &gapDetector{
+		last: make(map[string]uint64),
+		mu:   &sync.Mutex{},
+	}
+}
+
+// check records the payload's sequence number, and returns the gap before it, if any.
+//
+// The first event from a sender boot is never a gap, as there is nothing to compare it to.
+func (d *gapDetector) check(msg *EventPayload[json.RawMessage]) (gap *Gap) {
+	if msg.Sequence == 0 || msg.BootID == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	last, seen := d.last[msg.BootID]
+	if seen && msg.Sequence <= last {
+		// late delivery, already counted as missed if it was
+		return nil
+	}
+
+	d.last[msg.BootID] = msg.Sequence
+
+	if seen && msg.Sequence > last+1 {
+		return &Gap{
+			Sender:   msg.Sender,
+			BootID:   msg.BootID,
+			Expected: last + 1,
+			Got:      msg.Sequence,
+		}
+	}
+
+	return nil
+}
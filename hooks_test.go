@@ -0,0 +1,33 @@
+package wts
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHookWithOnExpired(t *testing.T) {
+	n := NewNode("http://localhost:9101/", "http://localhost:9100/")
+
+	var got []string
+	_, err := AddActorHook[string](n, "http://localhost:9102/light", nil, nil,
+		HookWithOnExpired(func(eventURL string, msg *EventPayload[string]) {
+			got = append(got, eventURL+" "+msg.Data)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := EncodeMessage("on", Expired, "http://localhost:9102/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventURL := "http://localhost:9102/light/expired"
+	n.handleEvent(eventURL, PayloadContentType, bytes.NewReader(content))
+
+	want := eventURL + " on"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("expired hook got %q, want [%q]", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package wts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notnotquinn/go-websub"
+)
+
+const (
+	// Default amount of time a hub is skipped for after it fails to answer.
+	defaultHubRetryInterval = 30 * time.Second
+)
+
+var (
+	// None of the node's hubs accepted a publish.
+	ErrNoHubAvailable = errors.New("could not publish to any hub")
+)
+
+// nodeHub is a hub the node publishes and subscribes through.
+type nodeHub struct {
+	// URL of the hub
+	url string
+	// publisher that publishes to this hub
+	publisher *websub.Publisher
+	// the hub is skipped when publishing until this date/time
+	downUntil time.Time
+}
+
+// WithHubs adds hubs for the node to use alongside the hub passed to NewNode.
+//
+// Events are published to every hub that is answering, and subscriptions are
+// made through each hub. Duplicate deliveries are dropped on receipt.
+// When a hub stops answering it is skipped until the retry interval has passed.
+func WithHubs(hubURLs ...string) NodeOption {
+	return func(n *Node) {
+		n.hubURLs = append(n.hubURLs, hubURLs...)
+	}
+}
+
+// WithHubRetryInterval sets how long a hub that failed to answer is skipped
+// when publishing. Defaults to 30 seconds.
+func WithHubRetryInterval(interval time.Duration) NodeOption {
+	return func(n *Node) {
+		n.hubRetryInterval = interval
+	}
+}
+
+// HubURLs returns the URLs of all hubs the node uses.
+func (n *Node) HubURLs() []string {
+	urls := make([]string, 0, len(n.hubs))
+	for _, hub := range n.hubs {
+		urls = append(urls, hub.url)
+	}
+
+	return urls
+}
+
+// publish publishes content to all hubs that are answering,
+// and succeeds if at least one of them accepted it.
+func (n *Node) publish(topic, contentType string, content []byte) (err error) {
+	defer func() {
+		n.metrics.published(topic, contentType, content, err)
+	}()
+
+	now := time.Now()
+
+	n.hubsMu.RLock()
+	var hubs []*nodeHub
+	for _, hub := range n.hubs {
+		if now.After(hub.downUntil) {
+			hubs = append(hubs, hub)
+		}
+	}
+	n.hubsMu.RUnlock()
+
+	if len(hubs) == 0 {
+		// nothing is answering, so try everything
+		hubs = n.hubs
+	}
+
+	var published bool
+	var lastErr error
+	for _, hub := range hubs {
+		err := hub.publisher.Publish(topic, contentType, content)
+		if err != nil {
+			log.Err(err).
+				Str("hub", hub.url).
+				Str("topic", topic).
+				Msg("hub did not accept publish")
+
+			n.hubsMu.Lock()
+			hub.downUntil = time.Now().Add(n.hubRetryInterval)
+			n.hubsMu.Unlock()
+
+			lastErr = err
+			continue
+		}
+
+		published = true
+	}
+
+	if !published {
+		return fmt.Errorf("%w: %s", ErrNoHubAvailable, lastErr)
+	}
+
+	return nil
+}
+
+// subscriptionHubs returns the hubs to subscribe to a topic through.
+//
+// With a single hub, the hub advertised by the topic is used.
+func (n *Node) subscriptionHubs() []string {
+	if len(n.hubs) == 1 {
+		return []string{""}
+	}
+
+	return n.HubURLs()
+}
+
+// discoveryURL returns a URL served by the node that advertises
+// the topic as being available on the hub.
+func (n *Node) discoveryURL(topic, hubURL string) string {
+	for i, hub := range n.hubs {
+		if hub.url == hubURL {
+			return n.baseURL + "/_d/" + strconv.Itoa(i) + "?topic=" + url.QueryEscape(topic)
+		}
+	}
+
+	return topic
+}
+
+// handleDiscovery advertises a topic on one of the node's hubs, so
+// the subscriber can subscribe to the topic through a specific hub.
+func (n *Node) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	i, err := strconv.Atoi(strings.Trim(r.URL.Path, "/"))
+	if err != nil || i < 0 || i >= len(n.hubs) {
+		http.NotFound(w, r)
+		return
+	}
+
+	topic := r.URL.Query().Get("topic")
+	if topic == "" {
+		http.Error(w, "missing 'topic' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Link", linkHeader(topic, n.hubs[i].url))
+	w.WriteHeader(http.StatusOK)
+}
+
+// linkHeader returns a Link header advertising the topic on the hubs.
+func linkHeader(topic string, hubURLs ...string) string {
+	links := []string{fmt.Sprintf(`<%s>; rel="self"`, topic)}
+	for _, hubURL := range hubURLs {
+		links = append(links, fmt.Sprintf(`<%s>; rel="hub"`, hubURL))
+	}
+
+	return strings.Join(links, ", ")
+}
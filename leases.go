@@ -0,0 +1,333 @@
+package wts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notnotquinn/go-websub"
+)
+
+const (
+	// Default interval between checks of subscription leases.
+	defaultLeaseCheckInterval = time.Minute
+	// How long a hub has to verify a subscription before it is considered lost.
+	verificationTimeout = time.Minute
+)
+
+var (
+	// The hub denied a subscription request.
+	ErrSubscriptionDenied = errors.New("subscription denied by hub")
+	// A subscription lease expired before it could be renewed.
+	ErrLeaseExpired = errors.New("subscription lease expired")
+	// The hub never verified a subscription request.
+	ErrSubscriptionUnverified = errors.New("subscription was not verified by hub")
+)
+
+// OnUnsubscribedFunc is called when the node loses a subscription without
+// asking for it to be removed. The node will try to subscribe to the topic again.
+type OnUnsubscribedFunc func(topic string, err error)
+
+// SubscriptionLease describes the lease of a subscription made by a Node.
+type SubscriptionLease struct {
+	// The topic subscribed to.
+	Topic string `json:"topic"`
+	// The hub the subscription was made through.
+	Hub string `json:"hub"`
+	// When the hub verified the subscription. Zero if not yet verified.
+	Verified time.Time `json:"verified"`
+	// When the lease expires. Zero if not yet verified.
+	Expires time.Time `json:"expires"`
+}
+
+// subscriptionKey identifies a subscription to a topic through a hub.
+type subscriptionKey struct {
+	topic string
+	hub   string
+}
+
+// nodeSubscription is a subscription the node depends on to function.
+type nodeSubscription struct {
+	// topic requested by the node
+	topic string
+	// hub the subscription is made through, empty for the hub advertised by the topic
+	hub string
+	// the websub subscription, nil while the node is re-subscribing
+	sub *websub.SubscriberSubscription
+	// date/time the subscription request was sent
+	requested time.Time
+}
+
+// subscriptionLease is what a hub reported when verifying a subscription.
+type subscriptionLease struct {
+	// date/time the hub verified the subscription
+	verified time.Time
+	// date/time the lease expires
+	expires time.Time
+	// set when the hub denied the subscription
+	denied error
+}
+
+// renewAt returns when the lease should be renewed,
+// which is once less than a fifth of the lease is left.
+func (l *subscriptionLease) renewAt() time.Time {
+	return l.verified.Add(l.expires.Sub(l.verified) * 4 / 5)
+}
+
+// WithOnUnsubscribed sets a function to be called when a subscription is lost
+// unexpectedly, such as when the hub denies it or its lease expires.
+func WithOnUnsubscribed(onUnsubscribed OnUnsubscribedFunc) NodeOption {
+	return func(n *Node) {
+		n.onUnsubscribed = onUnsubscribed
+	}
+}
+
+// WithLeaseCheckInterval sets how often subscription leases are checked
+// for renewal. Defaults to one minute.
+func WithLeaseCheckInterval(interval time.Duration) NodeOption {
+	return func(n *Node) {
+		n.leaseCheckInterval = interval
+	}
+}
+
+// Leases returns the leases of all subscriptions the node currently holds.
+func (n *Node) Leases() []SubscriptionLease {
+	n.subscriptionsMu.RLock()
+	defer n.subscriptionsMu.RUnlock()
+
+	leases := make([]SubscriptionLease, 0, len(n.subscriptions))
+	for _, ns := range n.subscriptions {
+		lease := SubscriptionLease{Topic: ns.topic, Hub: ns.hub}
+
+		if ns.sub != nil {
+			lease.Hub = ns.sub.Hub
+
+			if l, ok := n.leases[ns.sub.ID]; ok && l.denied == nil {
+				lease.Verified = l.verified
+				lease.Expires = l.expires
+			}
+		}
+
+		leases = append(leases, lease)
+	}
+
+	return leases
+}
+
+// statusRecorder records the status code written to a http.ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// handleCallback records what the hub reports when verifying subscriptions,
+// and passes the request on to the subscriber.
+func (n *Node) handleCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		n.Subscriber.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	n.Subscriber.ServeHTTP(rec, r)
+
+	subID := strings.TrimPrefix(r.URL.Path, "/")
+	q := r.URL.Query()
+
+	switch q.Get("hub.mode") {
+	case "subscribe":
+		if rec.status != http.StatusOK {
+			return
+		}
+
+		seconds, err := strconv.Atoi(q.Get("hub.lease_seconds"))
+		if err != nil {
+			return
+		}
+
+		topic := q.Get("hub.topic")
+
+		now := time.Now()
+		n.subscriptionsMu.Lock()
+		n.leases[subID] = &subscriptionLease{
+			verified: now,
+			expires:  now.Add(time.Duration(seconds) * time.Second),
+		}
+		fetched := n.retainedFetched[topic]
+		n.retainedFetched[topic] = true
+		n.subscriptionsMu.Unlock()
+
+		if !fetched {
+			go n.fetchRetained(topic)
+		}
+
+	case "denied":
+		n.subscriptionsMu.Lock()
+		if !n.ownsSubscription(subID) {
+			// not a subscription of this node
+			n.subscriptionsMu.Unlock()
+			return
+		}
+
+		n.leases[subID] = &subscriptionLease{
+			denied: fmt.Errorf("%w: %s", ErrSubscriptionDenied, q.Get("hub.reason")),
+		}
+		n.subscriptionsMu.Unlock()
+
+		// check now instead of waiting for the next interval
+		select {
+		case n.leaseChecks <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ownsSubscription returns whether the subscription with the ID is one
+// of the node's subscriptions. The subscriptions must be locked.
+func (n *Node) ownsSubscription(subID string) bool {
+	for _, ns := range n.subscriptions {
+		if ns.sub != nil && ns.sub.ID == subID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maintainSubscriptions keeps the node's subscriptions alive until stop is closed.
+func (n *Node) maintainSubscriptions(stop <-chan struct{}) {
+	t := time.NewTicker(n.leaseCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		case <-n.leaseChecks:
+		}
+
+		n.checkSubscriptions()
+	}
+}
+
+// checkSubscriptions renews leases that are about to expire,
+// and re-subscribes to topics whose subscriptions were lost.
+func (n *Node) checkSubscriptions() {
+	now := time.Now()
+
+	var renew []*nodeSubscription
+	var lost []error
+
+	n.subscriptionsMu.Lock()
+	for _, ns := range n.subscriptions {
+		if ns.sub == nil {
+			// lost before, and could not re-subscribe
+			renew = append(renew, ns)
+			lost = append(lost, nil)
+			continue
+		}
+
+		var err error
+		lease, verified := n.leases[ns.sub.ID]
+
+		switch {
+		case !verified:
+			if now.Sub(ns.requested) < verificationTimeout {
+				continue
+			}
+			err = ErrSubscriptionUnverified
+
+		case lease.denied != nil:
+			err = lease.denied
+
+		case now.After(lease.expires):
+			err = ErrLeaseExpired
+
+		case now.After(lease.renewAt()):
+			// still alive
+
+		default:
+			continue
+		}
+
+		if err != nil {
+			delete(n.leases, ns.sub.ID)
+			ns.sub = nil
+		}
+
+		renew = append(renew, ns)
+		lost = append(lost, err)
+	}
+	n.subscriptionsMu.Unlock()
+
+	for i, ns := range renew {
+		if lost[i] != nil {
+			log.Warn().
+				AnErr("reason", lost[i]).
+				Str("topic", ns.topic).
+				Str("hub", ns.hub).
+				Msg("subscription lost")
+
+			if n.onUnsubscribed != nil {
+				n.onUnsubscribed(ns.topic, lost[i])
+			}
+		}
+
+		n.renewSubscription(ns)
+	}
+}
+
+// renewSubscription replaces the subscription with a new one,
+// and unsubscribes from the old one if it is still active.
+func (n *Node) renewSubscription(ns *nodeSubscription) {
+	sub, err := n.newSubscription(ns.topic, ns.hub)
+	if err != nil {
+		log.Err(err).
+			Str("topic", ns.topic).
+			Str("hub", ns.hub).
+			Msg("could not renew subscription")
+		return
+	}
+
+	n.subscriptionsMu.Lock()
+	key := subscriptionKey{topic: ns.topic, hub: ns.hub}
+	if !n.subscribed || n.subscriptions[key] != ns {
+		// unsubscribed while renewing, which already removed the old subscription
+		n.subscriptionsMu.Unlock()
+
+		err := n.Unsubscribe(sub)
+		if err != nil {
+			log.Err(err).
+				Str("topic", ns.topic).
+				Msg("could not unsubscribe from subscription renewed after unsubscribing")
+		}
+
+		return
+	}
+
+	old := ns.sub
+	ns.sub = sub
+	ns.requested = time.Now()
+	if old != nil {
+		delete(n.leases, old.ID)
+	}
+	n.subscriptionsMu.Unlock()
+
+	if old != nil {
+		err := n.Unsubscribe(old)
+		if err != nil {
+			log.Err(err).
+				Str("topic", ns.topic).
+				Msg("could not unsubscribe from renewed subscription")
+		}
+	}
+}
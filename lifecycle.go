@@ -0,0 +1,266 @@
+package wts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	// No emitter with the name was added to the node.
+	ErrEmitterNotFound = errors.New("emitter not found")
+)
+
+// RunnableEmitter is implemented by emitters that only produce data events
+// while running, such as emitters that poll a data source.
+//
+// The node calls Run once the emitter is added or started, and cancels the
+// context once it is stopped. The emitter is considered erroring if Run
+// returns an error before the context is canceled.
+type RunnableEmitter interface {
+	// Run produces data events until the context is canceled.
+	Run(ctx context.Context) error
+}
+
+// ErrorReporter is implemented by emitters that report errors
+// that do not stop them, such as a failed poll.
+type ErrorReporter interface {
+	// Returns a channel that passes errors to be reported.
+	Errors() <-chan error
+}
+
+// EmitterState is what an emitter is doing.
+type EmitterState string
+
+const (
+	// The emitter's data events are being published.
+	EmitterRunning EmitterState = "running"
+	// The emitter was stopped, or has no more data events.
+	EmitterStopped EmitterState = "stopped"
+	// The emitter reported an error since its last data event, or stopped
+	// because of an error.
+	EmitterErroring EmitterState = "erroring"
+)
+
+// EmitterStatus describes an emitter added to a node.
+type EmitterStatus struct {
+	// The URL of the emitter.
+	URL string `json:"url"`
+	// What the emitter is doing.
+	State EmitterState `json:"state"`
+	// The last error reported by the emitter, if any.
+	Error string `json:"error,omitempty"`
+	// When the last error was reported. Zero if none was.
+	ErrorAt time.Time `json:"errorAt,omitempty"`
+	// The number of data events emitted since the emitter was added.
+	Events uint64 `json:"events"`
+	// When the last data event was emitted. Zero if none was.
+	LastEvent time.Time `json:"lastEvent,omitempty"`
+}
+
+// NodeStatus describes the state of a node.
+type NodeStatus struct {
+	// The base URL of the node.
+	BaseURL string `json:"baseURL"`
+	// The boot ID of the node.
+	BootID string `json:"bootID"`
+	// Whether the node is subscribed to the topics it needs.
+	Subscribed bool `json:"subscribed"`
+	// The hubs of the node.
+	Hubs []string `json:"hubs"`
+	// The emitters added to the node.
+	Emitters []EmitterStatus `json:"emitters"`
+	// The leases of the node's subscriptions.
+	Leases []SubscriptionLease `json:"leases"`
+}
+
+// WithStatusPath serves the node's status as JSON on the path,
+// such as "/_status". Paths should not conflict with entity names.
+func WithStatusPath(path string) NodeOption {
+	return func(n *Node) {
+		n.mux.Handle(path, http.HandlerFunc(n.handleStatus))
+	}
+}
+
+// Status returns the state of the node and its emitters.
+func (n *Node) Status() NodeStatus {
+	status := NodeStatus{
+		BaseURL:    n.baseURL,
+		BootID:     n.bootID,
+		Subscribed: n.subscribed,
+		Hubs:       n.HubURLs(),
+		Leases:     n.Leases(),
+	}
+
+	n.emittersMu.RLock()
+	for _, emitter := range n.emitters {
+		status.Emitters = append(status.Emitters, emitter.status())
+	}
+	n.emittersMu.RUnlock()
+
+	sort.Slice(status.Emitters, func(i, j int) bool {
+		return status.Emitters[i].URL < status.Emitters[j].URL
+	})
+
+	return status
+}
+
+// handleStatus serves the node's status.
+func (n *Node) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(n.Status())
+	if err != nil {
+		log.Err(err).Msg("could not write node status")
+	}
+}
+
+// StartEmitter starts an emitter added to the node that was stopped.
+// Does nothing if it is running.
+func (n *Node) StartEmitter(name string) error {
+	emitter, err := n.getEmitter(name)
+	if err != nil {
+		return err
+	}
+
+	emitter.start()
+	return nil
+}
+
+// StopEmitter stops an emitter added to the node, so its data events are no
+// longer published. Does nothing if it is stopped.
+func (n *Node) StopEmitter(name string) error {
+	emitter, err := n.getEmitter(name)
+	if err != nil {
+		return err
+	}
+
+	emitter.stop()
+	return nil
+}
+
+// getEmitter gets an emitter added to the node by its name.
+func (n *Node) getEmitter(name string) (*emitterProxy, error) {
+	n.emittersMu.RLock()
+	emitter, exists := n.emitters[n.baseURL+"/"+name]
+	n.emittersMu.RUnlock()
+
+	if !exists {
+		return nil, ErrEmitterNotFound
+	}
+
+	return emitter, nil
+}
+
+// start runs the emitter, and publishes its data events. Does nothing if it is running.
+func (p *emitterProxy) start() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	if p.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.state = EmitterRunning
+
+	go p.forward(ctx)
+
+	if p.runnable != nil {
+		go func() {
+			err := p.runnable.Run(ctx)
+			p.finished(ctx, err)
+		}()
+	}
+}
+
+// stop stops the emitter. Does nothing if it is stopped.
+func (p *emitterProxy) stop() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	if p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	p.cancel = nil
+	p.state = EmitterStopped
+}
+
+// finished records that the run of the emitter with the context ended by itself.
+func (p *emitterProxy) finished(ctx context.Context, err error) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	if ctx.Err() != nil {
+		// stopped already
+		return
+	}
+
+	p.cancel()
+	p.cancel = nil
+	p.state = EmitterStopped
+
+	if err != nil {
+		log.Err(err).
+			Str("emitterURL", p.url).
+			Msg("emitter stopped with an error")
+
+		p.state = EmitterErroring
+		p.lastErr = err
+		p.lastErrAt = time.Now()
+	}
+}
+
+// emitted records that the emitter emitted a data event.
+func (p *emitterProxy) emitted() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	p.events++
+	p.lastEvent = time.Now()
+	if p.state == EmitterErroring && p.cancel != nil {
+		p.state = EmitterRunning
+	}
+}
+
+// reportErrors records the errors reported by the emitter.
+func (p *emitterProxy) reportErrors(errs <-chan error) {
+	for err := range errs {
+		log.Err(err).
+			Str("emitterURL", p.url).
+			Msg("emitter reported an error")
+
+		p.stateMu.Lock()
+		p.lastErr = err
+		p.lastErrAt = time.Now()
+		if p.state == EmitterRunning {
+			p.state = EmitterErroring
+		}
+		p.stateMu.Unlock()
+	}
+}
+
+// status describes the emitter.
+func (p *emitterProxy) status() EmitterStatus {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	status := EmitterStatus{
+		URL:       p.url,
+		State:     p.state,
+		ErrorAt:   p.lastErrAt,
+		Events:    p.events,
+		LastEvent: p.lastEvent,
+	}
+
+	if p.lastErr != nil {
+		status.Error = p.lastErr.Error()
+	}
+
+	return status
+}
@@ -0,0 +1,223 @@
+package wts
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// limitFunc passes on a subset of the data events from in to out, until in is
+// closed or ctx is canceled. Data events that are held back are dropped when
+// ctx is canceled, and passed on when in is closed.
+type limitFunc[MsgType any] func(ctx context.Context, in <-chan MsgType, out chan<- MsgType)
+
+// limitedEmitter is an emitter that passes on a subset
+// of the data events of another emitter while running.
+type limitedEmitter[MsgType any] struct {
+	// the wrapped emitter
+	Emitter[MsgType]
+	// passes on the data events
+	limit limitFunc[MsgType]
+	// the data events that are passed on
+	ch chan MsgType
+	// closes ch once the wrapped emitter's data events are closed
+	closeOnce *sync.Once
+}
+
+// DataEvents returns a channel that passes the data events that are passed on.
+func (e *limitedEmitter[MsgType]) DataEvents() <-chan MsgType {
+	return e.ch
+}
+
+// Run passes on data events until the context is canceled, and runs the
+// wrapped emitter if it is a RunnableEmitter.
+func (e *limitedEmitter[MsgType]) Run(ctx context.Context) error {
+	go func() {
+		e.limit(ctx, e.Emitter.DataEvents(), e.ch)
+		if ctx.Err() == nil {
+			// the wrapped emitter's data events were closed
+			e.closeOnce.Do(func() { close(e.ch) })
+		}
+	}()
+
+	if r, ok := e.Emitter.(RunnableEmitter); ok {
+		return r.Run(ctx)
+	}
+
+	<-ctx.Done()
+	return nil
+}
+
+// Errors returns the errors of the wrapped emitter if it is an ErrorReporter,
+// otherwise nil.
+func (e *limitedEmitter[MsgType]) Errors() <-chan error {
+	if r, ok := e.Emitter.(ErrorReporter); ok {
+		return r.Errors()
+	}
+
+	return nil
+}
+
+// newLimitedEmitter wraps an emitter, and runs limit with the wrapped
+// emitter's data events and the channel to pass data events on to
+// while the emitter is running.
+func newLimitedEmitter[MsgType any](e Emitter[MsgType], limit limitFunc[MsgType]) Emitter[MsgType] {
+	return &limitedEmitter[MsgType]{
+		Emitter:   e,
+		limit:     limit,
+		ch:        make(chan MsgType),
+		closeOnce: &sync.Once{},
+	}
+}
+
+// send passes a data event on, and returns false if ctx was canceled first.
+func send[MsgType any](ctx context.Context, out chan<- MsgType, msg MsgType) bool {
+	select {
+	case out <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// NewThrottledEmitter wraps an emitter to publish at most one data event per interval.
+// Data events sent less than interval after the last published one are dropped.
+func NewThrottledEmitter[MsgType any](e Emitter[MsgType], interval time.Duration) Emitter[MsgType] {
+	return newLimitedEmitter(e, func(ctx context.Context, in <-chan MsgType, out chan<- MsgType) {
+		var last time.Time
+		for {
+			select {
+			case msg, ok := <-in:
+				if !ok {
+					return
+				}
+
+				if time.Since(last) < interval {
+					continue
+				}
+
+				last = time.Now()
+				if !send(ctx, out, msg) {
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// NewDebouncedEmitter wraps an emitter to only publish a data event once no other
+// data events were sent for the quiet period. Only the last data event is published.
+func NewDebouncedEmitter[MsgType any](e Emitter[MsgType], quiet time.Duration) Emitter[MsgType] {
+	return newLimitedEmitter(e, func(ctx context.Context, in <-chan MsgType, out chan<- MsgType) {
+		t := time.NewTimer(quiet)
+		defer t.Stop()
+		t.Stop()
+
+		var pending MsgType
+		var hasPending bool
+		for {
+			// the timer only matters while a data event is pending
+			var quietOver <-chan time.Time
+			if hasPending {
+				quietOver = t.C
+			}
+
+			select {
+			case msg, ok := <-in:
+				if !ok {
+					if hasPending {
+						send(ctx, out, pending)
+					}
+					return
+				}
+
+				if !t.Stop() {
+					// timer fired but was not received yet
+					select {
+					case <-t.C:
+					default:
+					}
+				}
+				pending, hasPending = msg, true
+				t.Reset(quiet)
+
+			case <-quietOver:
+				hasPending = false
+				if !send(ctx, out, pending) {
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// NewSampledEmitter wraps an emitter to publish the latest data event once every
+// interval. Nothing is published for an interval without new data events.
+func NewSampledEmitter[MsgType any](e Emitter[MsgType], interval time.Duration) Emitter[MsgType] {
+	return newLimitedEmitter(e, func(ctx context.Context, in <-chan MsgType, out chan<- MsgType) {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		var latest MsgType
+		var hasLatest bool
+		for {
+			select {
+			case msg, ok := <-in:
+				if !ok {
+					if hasLatest {
+						send(ctx, out, latest)
+					}
+					return
+				}
+				latest, hasLatest = msg, true
+
+			case <-t.C:
+				if hasLatest {
+					hasLatest = false
+					if !send(ctx, out, latest) {
+						return
+					}
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// NewOnChangeEmitter wraps an emitter to only publish data events that
+// are different from the last published one, compared with reflect.DeepEqual.
+func NewOnChangeEmitter[MsgType any](e Emitter[MsgType]) Emitter[MsgType] {
+	return newLimitedEmitter(e, func(ctx context.Context, in <-chan MsgType, out chan<- MsgType) {
+		var last MsgType
+		var hasLast bool
+		for {
+			select {
+			case msg, ok := <-in:
+				if !ok {
+					return
+				}
+
+				if hasLast && reflect.DeepEqual(last, msg) {
+					continue
+				}
+
+				last, hasLast = msg, true
+				if !send(ctx, out, msg) {
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package wts
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receive returns the next data event of e, or false if there is none within wait.
+func receive(e Emitter[int], wait time.Duration) (int, bool) {
+	select {
+	case msg := <-e.DataEvents():
+		return msg, true
+	case <-time.After(wait):
+		return 0, false
+	}
+}
+
+func TestDebouncedEmitter(t *testing.T) {
+	const quiet = 20 * time.Millisecond
+
+	in := make(chan int)
+	e := NewDebouncedEmitter(NewBasicEmitter("in", in), quiet)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.(RunnableEmitter).Run(ctx)
+
+	if msg, ok := receive(e, 5*quiet); ok {
+		t.Fatalf("got %d before any data event was sent", msg)
+	}
+
+	in <- 1
+	in <- 2
+	in <- 3
+
+	msg, ok := receive(e, 10*quiet)
+	if !ok || msg != 3 {
+		t.Fatalf("got %d, %v, want 3, true", msg, ok)
+	}
+
+	if msg, ok := receive(e, 5*quiet); ok {
+		t.Fatalf("got %d after the last data event was passed on", msg)
+	}
+}
+
+func TestLimitedEmitterRestart(t *testing.T) {
+	const quiet = 20 * time.Millisecond
+
+	in := make(chan int)
+	e := NewDebouncedEmitter(NewBasicEmitter("in", in), quiet)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go e.(RunnableEmitter).Run(ctx)
+
+	// stopped while the data event is held back
+	in <- 1
+	cancel()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go e.(RunnableEmitter).Run(ctx)
+
+	if msg, ok := receive(e, 5*quiet); ok {
+		t.Fatalf("got %d from before the emitter was stopped", msg)
+	}
+
+	in <- 2
+	msg, ok := receive(e, 10*quiet)
+	if !ok || msg != 2 {
+		t.Fatalf("got %d, %v, want 2, true", msg, ok)
+	}
+}
+
+func TestLimitedEmitterClosed(t *testing.T) {
+	in := make(chan int)
+	e := NewOnChangeEmitter(NewBasicEmitter("in", in))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.(RunnableEmitter).Run(ctx)
+
+	go func() {
+		in <- 1
+		in <- 1
+		in <- 2
+		close(in)
+	}()
+
+	var got []int
+	for msg := range e.DataEvents() {
+		got = append(got, msg)
+	}
+
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("got %v, want [1 2]", got)
+	}
+}
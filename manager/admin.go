@@ -0,0 +1,382 @@
+package manager
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/notnotquinn/wts"
+)
+
+const (
+	// Path the admin API is served on.
+	adminPath = "/admin/"
+	// Number of rule evaluations kept for the admin API.
+	maxEvaluations = 100
+)
+
+var (
+	errNotFound = errors.New("not found")
+)
+
+// RuleEvaluation describes a rule being triggered.
+type RuleEvaluation struct {
+	// The rule that was triggered.
+	Rule string `json:"rule"`
+	// The trigger that triggered the rule, empty if triggered through the admin API.
+	Trigger string `json:"trigger,omitempty"`
+	// The event URL of the event that triggered the rule.
+	EventURL string `json:"eventURL,omitempty"`
+	// When the rule was triggered.
+	Time time.Time `json:"time"`
+	// How long evaluating the rule took.
+	Duration time.Duration `json:"duration"`
+	// The actions performed.
+	Actions []string `json:"actions"`
+	// The error evaluating the rule, in which case variables were not modified.
+	Error string `json:"error,omitempty"`
+}
+
+// adminRule describes a rule in the admin API.
+type adminRule struct {
+	Name     string            `json:"name"`
+	Enabled  bool              `json:"enabled"`
+	Triggers map[string]string `json:"triggers"`
+	Actions  map[string]bool   `json:"actions"`
+}
+
+// adminTrigger is the body of a request to trigger a rule manually.
+type adminTrigger struct {
+	// Trigger whose variable modifiers are applied, if any.
+	Trigger string `json:"trigger"`
+	// Event URL of the message, defaults to the trigger's event.
+	EventURL string `json:"eventURL"`
+	// Data of the message.
+	Data any `json:"data"`
+}
+
+// actionKey returns the key an action is identified by.
+func actionKey(ruleName, actionName string) string {
+	return ruleName + "/" + actionName
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(set))
+	for k, v := range set {
+		c[k] = v
+	}
+
+	return c
+}
+
+// recordEvaluation records a rule evaluation for the admin API.
+func (m *Manager) recordEvaluation(tctx *TriggerContext, started time.Time, err error) {
+	e := RuleEvaluation{
+		Rule:     tctx.ruleName,
+		Trigger:  tctx.triggerName,
+		Time:     started,
+		Duration: time.Since(started),
+		EventURL: tctx.eventURL,
+		Actions:  tctx.actions,
+	}
+
+	if e.Actions == nil {
+		e.Actions = []string{}
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	m.evaluationsMu.Lock()
+	m.evaluations = append(m.evaluations, e)
+	if len(m.evaluations) > maxEvaluations {
+		m.evaluations = m.evaluations[len(m.evaluations)-maxEvaluations:]
+	}
+	m.evaluationsMu.Unlock()
+}
+
+// Evaluations returns the most recent rule evaluations, oldest first.
+func (m *Manager) Evaluations() []RuleEvaluation {
+	m.evaluationsMu.Lock()
+	defer m.evaluationsMu.Unlock()
+
+	return append([]RuleEvaluation(nil), m.evaluations...)
+}
+
+// SetRuleEnabled enables or disables a rule. Disabled rules are not
+// triggered by events, but can still be triggered manually.
+func (m *Manager) SetRuleEnabled(ruleName string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.Config.Rules[ruleName]; !ok {
+		return fmt.Errorf("rule %q: %w", ruleName, errNotFound)
+	}
+
+	m.disabledRules[ruleName] = !enabled
+	return nil
+}
+
+// SetActionEnabled enables or disables an action of a rule.
+func (m *Manager) SetActionEnabled(ruleName, actionName string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.Config.Rules[ruleName].Actions[actionName]; !ok {
+		return fmt.Errorf("action %q of rule %q: %w", actionName, ruleName, errNotFound)
+	}
+
+	m.disabledActions[actionKey(ruleName, actionName)] = !enabled
+	return nil
+}
+
+// TriggerRule triggers a rule with a message as if it was received on the event URL,
+// applying the variable modifiers of the trigger if it is not empty.
+func (m *Manager) TriggerRule(ruleName, triggerName, eventURL string, msg *wts.EventPayload[any]) error {
+	m.mu.Lock()
+	config := m.Config
+	disabledActions := copySet(m.disabledActions)
+	m.mu.Unlock()
+
+	rule, ok := config.Rules[ruleName]
+	if !ok {
+		return fmt.Errorf("rule %q: %w", ruleName, errNotFound)
+	}
+
+	if _, ok := rule.Triggers[triggerName]; !ok && triggerName != "" {
+		return fmt.Errorf("trigger %q of rule %q: %w", triggerName, ruleName, errNotFound)
+	}
+
+	return m.evaluateRule(&TriggerContext{
+		ruleName:        ruleName,
+		triggerName:     triggerName,
+		eventURL:        eventURL,
+		message:         msg,
+		config:          config,
+		disabledActions: disabledActions,
+	})
+}
+
+// SetVariable sets a variable, by the key it is stored under.
+// The value must match the type of the variable.
+func (m *Manager) SetVariable(key string, value any) error {
+	m.mu.Lock()
+	variable, ok := m.Config.variables()[key]
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("variable %q: %w", key, errNotFound)
+	}
+
+	err := variable.Type.check(value)
+	if err != nil {
+		return fmt.Errorf("variable %q: %w", key, err)
+	}
+
+	return m.Variables.Update(func(tx *VariableTx) error {
+		tx.Set(key, value)
+		return nil
+	})
+}
+
+// handleAdmin serves the admin API:
+//
+//	GET  /rules                                list rules
+//	POST /rules/{rule}/enable                  enable a rule
+//	POST /rules/{rule}/disable                 disable a rule
+//	POST /rules/{rule}/actions/{action}/enable  enable an action
+//	POST /rules/{rule}/actions/{action}/disable disable an action
+//	POST /rules/{rule}/trigger                 trigger a rule with a message
+//	GET  /vars                                 get all variables
+//	GET  /vars/{key}                           get a variable
+//	PUT  /vars/{key}                           set a variable to the JSON body
+//	GET  /evaluations                          recent rule evaluations
+//	GET  /pending                              pending delayed actions
+//	POST /pending/{rule}/{action}/cancel       cancel a pending action
+//	POST /reload                               reload the config file
+//
+// Rule-local variables are identified by "{rule}/{name}".
+func (m *Manager) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	token := m.Config.AdminToken
+	m.mu.Unlock()
+
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	auth := r.Header.Get("Authorization")
+	given := strings.TrimPrefix(auth, "Bearer ")
+	if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := r.Method + " " + path[0]
+
+	var err error
+	switch {
+	case route == "GET rules" && len(path) == 1:
+		writeJSON(w, http.StatusOK, m.adminRules())
+
+	case route == "POST rules" && len(path) == 3 && (path[2] == "enable" || path[2] == "disable"):
+		err = m.SetRuleEnabled(path[1], path[2] == "enable")
+
+	case route == "POST rules" && len(path) == 5 && path[2] == "actions" && (path[4] == "enable" || path[4] == "disable"):
+		err = m.SetActionEnabled(path[1], path[3], path[4] == "enable")
+
+	case route == "POST rules" && len(path) == 3 && path[2] == "trigger":
+		err = m.adminTriggerRule(r, path[1])
+
+	case route == "GET vars" && len(path) == 1:
+		writeJSON(w, http.StatusOK, m.Variables.Snapshot())
+
+	case route == "GET vars" && len(path) > 1:
+		value, ok := m.Variables.Get(strings.Join(path[1:], "/"))
+		if !ok {
+			err = errNotFound
+			break
+		}
+		writeJSON(w, http.StatusOK, value)
+
+	case route == "PUT vars" && len(path) > 1:
+		var value any
+		err = json.NewDecoder(r.Body).Decode(&value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err = m.SetVariable(strings.Join(path[1:], "/"), value)
+
+	case route == "GET evaluations" && len(path) == 1:
+		writeJSON(w, http.StatusOK, m.Evaluations())
+
+	case route == "GET pending" && len(path) == 1:
+		writeJSON(w, http.StatusOK, m.PendingActions())
+
+	case route == "POST pending" && len(path) == 4 && path[3] == "cancel":
+		if !m.CancelAction(path[1], path[2]) {
+			err = fmt.Errorf("pending action %q of rule %q: %w", path[2], path[1], errNotFound)
+		}
+
+	case route == "POST reload" && len(path) == 1:
+		errs := m.Reload()
+		if len(errs) != 0 {
+			messages := make([]string, len(errs))
+			for i, err := range errs {
+				messages[i] = err.Error()
+			}
+
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": messages})
+			return
+		}
+
+	default:
+		err = errNotFound
+	}
+
+	switch {
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusUnprocessableEntity, err)
+	case r.Method != http.MethodGet:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// adminRules describes all rules.
+func (m *Manager) adminRules() []adminRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make([]adminRule, 0, len(m.Config.Rules))
+	for ruleName, rule := range m.Config.Rules {
+		ar := adminRule{
+			Name:     ruleName,
+			Enabled:  !m.disabledRules[ruleName],
+			Triggers: make(map[string]string),
+			Actions:  make(map[string]bool),
+		}
+
+		for triggerName, trigger := range rule.Triggers {
+			switch {
+			case trigger.Event != nil:
+				ar.Triggers[triggerName] = *trigger.Event
+			case trigger.Every != nil:
+				ar.Triggers[triggerName] = "every " + *trigger.Every
+			case trigger.Cron != nil:
+				ar.Triggers[triggerName] = "cron " + *trigger.Cron
+			}
+		}
+
+		for actionName := range rule.Actions {
+			ar.Actions[actionName] = !m.disabledActions[actionKey(ruleName, actionName)]
+		}
+
+		rules = append(rules, ar)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+
+	return rules
+}
+
+// adminTriggerRule triggers a rule with the message in the request body.
+func (m *Manager) adminTriggerRule(r *http.Request, ruleName string) error {
+	var body adminTrigger
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		return err
+	}
+
+	eventURL := body.EventURL
+	if eventURL == "" {
+		m.mu.Lock()
+		trigger := m.Config.Rules[ruleName].Triggers[body.Trigger]
+		m.mu.Unlock()
+
+		if trigger.Event != nil {
+			eventURL = *trigger.Event
+		}
+	}
+
+	msg := &wts.EventPayload[any]{
+		Data:     body.Data,
+		DateSent: time.Now(),
+		Sender:   adminPath,
+	}
+
+	if eventURL != "" {
+		_, msg.EventType, err = wts.ParseEventURL(eventURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.TriggerRule(ruleName, body.Trigger, eventURL, msg)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Err(err).Msg("could not write admin API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/itchyny/gojq"
 	"github.com/notnotquinn/wts"
@@ -17,29 +19,153 @@ type Config struct {
 	// The port to expose itself on
 	HubPort int `yaml:"port"`
 	// The default value for all variables
-	InitialVars map[string]string `yaml:"vars"`
+	InitialVars map[string]ConfVariable `yaml:"vars"`
+	// The file persisted variables are stored in.
+	// Defaults to the config file's path with ".vars.json" appended.
+	VariablesFile string `yaml:"vars-file"`
+	// Token required to use the admin API, which is disabled if empty
+	AdminToken string `yaml:"admin-token"`
 	// Different rules for configuring logic
 	Rules map[string]ConfRule `yaml:"rules"`
+
+	// names of the rules in the order they are evaluated, set by validate
+	ruleNames []string
+	// maps rule name to the names of its actions in the order they are performed,
+	// set by validate
+	actionNames map[string][]string
+	// maps rule name to the names of its triggers in the order they are checked,
+	// set by validate
+	triggerNames map[string][]string
+}
+
+// ConfVariable declares a variable, either as its initial value, or as
+// a mapping with "value:", "type:" and "persist:" keys.
+//
+// Variables may hold any JSON value, but objects must be declared with the mapping.
+type ConfVariable struct {
+	// The initial value of the variable, the zero value of the type if not set
+	Value any `yaml:"value"`
+	// The type of value the variable holds, any if not set
+	Type VariableType `yaml:"type"`
+	// Whether the variable keeps its value when the manager restarts
+	Persist bool `yaml:"persist"`
+}
+
+// UnmarshalYAML allows variables to be declared as just their initial value.
+func (v *ConfVariable) UnmarshalYAML(node *yaml.Node) error {
+	var err error
+	if node.Kind == yaml.MappingNode {
+		// mappings are the long form, so objects must be under "value:"
+		for i := 0; i < len(node.Content); i += 2 {
+			switch key := node.Content[i].Value; key {
+			case "value", "type", "persist":
+			default:
+				return fmt.Errorf("line %d: unknown variable key %q, "+
+					"declare objects as 'value: {...}'", node.Content[i].Line, key)
+			}
+		}
+
+		// avoid recursing into this method
+		type plain ConfVariable
+		err = node.Decode((*plain)(v))
+	} else {
+		err = node.Decode(&v.Value)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if v.Value == nil {
+		v.Value = v.Type.zeroValue()
+	}
+
+	v.Value, err = normalizeJSON(v.Value)
+	return err
+}
+
+// localVariableKey returns the key a rule-local variable is stored under.
+func localVariableKey(ruleName, name string) string {
+	return ruleName + "/" + name
+}
+
+// variables returns all global and rule-local variables,
+// by the key they are stored under.
+func (c *Config) variables() map[string]ConfVariable {
+	vars := make(map[string]ConfVariable, len(c.InitialVars))
+	for name, v := range c.InitialVars {
+		vars[name] = v
+	}
+
+	for ruleName, rule := range c.Rules {
+		for name, v := range rule.Vars {
+			vars[localVariableKey(ruleName, name)] = v
+		}
+	}
+
+	return vars
+}
+
+// initialValues returns the initial value of every variable, by key.
+func (c *Config) initialValues() map[string]any {
+	values := make(map[string]any)
+	for key, v := range c.variables() {
+		values[key] = v.Value
+	}
+
+	return values
+}
+
+// persistedVariables returns the keys of the variables that persist.
+func (c *Config) persistedVariables() map[string]bool {
+	keys := make(map[string]bool)
+	for key, v := range c.variables() {
+		if v.Persist {
+			keys[key] = true
+		}
+	}
+
+	return keys
 }
 
 // ConfRule is a rule that can be triggered by triggers, and has variables local to itself.
 //
 // When a rule is triggered all of the rule's actions are also triggered.
+//
+// Rules triggered by the same event are evaluated after the rules they list in
+// After, and otherwise highest Priority first, then by name.
 type ConfRule struct {
-	Triggers map[string]ConfTrigger `yaml:"triggers"`
-	Actions  map[string]ConfAction  `yaml:"actions"`
+	// Variables only visible to the rule, which shadow global variables
+	Vars     map[string]ConfVariable `yaml:"vars"`
+	Triggers map[string]ConfTrigger  `yaml:"triggers"`
+	Actions  map[string]ConfAction   `yaml:"actions"`
+	Priority int                     `yaml:"priority"`
+	// Rules evaluated before this one
+	After []string `yaml:"after"`
+	// Whether rules after this one are skipped for events that trigger it
+	Stop bool `yaml:"stop"`
 }
 
 // ConfTrigger is a single trigger for a rule.
 //
 // When a trigger is triggered, it can optionally set certain variables.
+//
+// Exactly one of Event, Every and Cron must be set. Scheduled triggers
+// trigger the rule with a message containing the time they fired.
 type ConfTrigger struct {
-	Event      *string                         `yaml:"event"`
+	// The event URL that triggers the rule
+	Event *string `yaml:"event"`
+	// Triggers the rule repeatedly, at an interval such as "5m"
+	Every *string `yaml:"every"`
+	// Triggers the rule on a five field cron schedule, such as "0 7 * * 1-5"
+	Cron       *string                         `yaml:"cron"`
 	ModifyVars map[string]ConfVariableModifier `yaml:"modify-vars"`
 }
 
 // ConfVariableModifier modifies a variable when it is triggered to either as part of an action
 // or as part of a trigger.
+//
+// The modifiers of a trigger or action are applied in order of variable name.
 type ConfVariableModifier struct {
 	JSONQuery *string `yaml:"set"`
 	Reset     *bool   `yaml:"reset"`
@@ -49,11 +175,32 @@ type ConfVariableModifier struct {
 //
 // The action itself may have conditions that must be met for it to trigger,
 // other than the rule its a part of triggering.
+//
+// Actions are performed after the actions they list in After, and otherwise
+// highest Priority first, then by name.
+//
+// Actions with a delay are performed once it is over, unless they are cancelled.
+// Triggering a pending action again restarts its delay. Like events, delays and
+// cancellations only take effect if the rule's variable changes are committed.
 type ConfAction struct {
 	TriggerCondition *ConfTriggerCondition           `yaml:"if"`
 	ModifyVars       map[string]ConfVariableModifier `yaml:"modify-vars"`
 	EventJQ          string                          `yaml:"event"`
 	DataJQ           string                          `yaml:"data"`
+	// A duration such as "5m", or a jq query producing a duration or a number of seconds
+	Delay *string `yaml:"delay"`
+	// Pending actions to cancel, named "{action}" for actions of the same rule,
+	// or "{rule}/{action}". Actions that only cancel need no event or data.
+	Cancel   []string `yaml:"cancel"`
+	Priority int      `yaml:"priority"`
+	// Actions of the rule performed before this one
+	After []string `yaml:"after"`
+	// Broadcast an event for every result of the event and data queries instead of
+	// only the first, either "each" for every combination, or "zip" to pair them up.
+	// Nothing is broadcast if the queries return no results.
+	FanOut string `yaml:"fan-out"`
+	// Maximum events broadcast with fan-out, 100 if not set
+	MaxFanOut int `yaml:"max-fan-out"`
 }
 
 // ConfTriggerCondition specifies a condition.
@@ -96,26 +243,17 @@ func (c *Config) validate() (errs []error) {
 	var err error
 	globalVars := map[string]bool{}
 
-	for varName := range c.InitialVars {
-		var hadErr bool
-
-		if varName[0] == '_' {
-			hadErr = true
-			err = fmt.Errorf("variable name must not start with an underscore: %q", varName)
-			errs = append(errs, err)
-		}
-
-		if globalVars[varName] {
-			hadErr = true
-			err = fmt.Errorf("global variable %q redeclared", varName)
-			errs = append(errs, err)
-		}
+	for varName, v := range c.InitialVars {
+		validationErrors := validateVariable(varName, v)
+		errs = append(errs, validationErrors...)
 
-		if !hadErr {
+		if len(validationErrors) == 0 {
 			globalVars[varName] = true
 		}
 	}
 
+	c.actionNames = make(map[string][]string, len(c.Rules))
+	c.triggerNames = make(map[string][]string, len(c.Rules))
 	for ruleName, rule := range c.Rules {
 		validationErrors := rule.validate(globalVars)
 
@@ -124,15 +262,117 @@ func (c *Config) validate() (errs []error) {
 			err = fmt.Errorf("rules: %q: %w", ruleName, err2)
 			errs = append(errs, err)
 		}
+
+		c.actionNames[ruleName], err = rule.actionOrder()
+		if err != nil {
+			err = fmt.Errorf("rules: %q: actions: %w", ruleName, err)
+			errs = append(errs, err)
+		}
+
+		c.triggerNames[ruleName] = rule.triggerOrder()
+	}
+
+	errs = append(errs, c.validateCancels()...)
+
+	c.ruleNames, err = c.ruleOrder()
+	if err != nil {
+		err = fmt.Errorf("rules: %w", err)
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// validateCancels checks actions only cancel delayed actions that exist.
+func (c *Config) validateCancels() (errs []error) {
+	for ruleName, rule := range c.Rules {
+		for actionName, action := range rule.Actions {
+			for _, name := range action.Cancel {
+				cancelledRule, cancelledAction, _ := strings.Cut(cancelKey(ruleName, name), "/")
+				if c.Rules[cancelledRule].Actions[cancelledAction].Delay == nil {
+					err := fmt.Errorf("rules: %q: actions: %q: cancel: %q is not a delayed action", ruleName, actionName, name)
+					errs = append(errs, err)
+				}
+			}
+		}
 	}
 
 	return errs
 }
 
+// validVariableName matches variable names that can be used in jq.
+var validVariableName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// validateVariable checks a variable declaration is valid
+func validateVariable(varName string, v ConfVariable) (errs []error) {
+	var err error
+
+	if !v.Type.valid() {
+		err = fmt.Errorf("variable %q: unknown type %q", varName, v.Type)
+		errs = append(errs, err)
+	} else if err = v.Type.check(v.Value); err != nil {
+		err = fmt.Errorf("variable %q: initial value: %w", varName, err)
+		errs = append(errs, err)
+	}
+
+	if varName != "" && varName[0] == '_' {
+		err = fmt.Errorf("variable name must not start with an underscore: %q", varName)
+		errs = append(errs, err)
+	} else if !validVariableName.MatchString(varName) || varName == "ENV" {
+		err = fmt.Errorf("variable name is not a valid jq variable name: %q", varName)
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// builtinVariables are the variables available to every jq query in a rule.
+var builtinVariables = []string{"$_ruleName", "$_triggerName", "$_msg"}
+
+// validateJQ checks a jq query parses, and only references declared variables.
+func validateJQ(query string, vars map[string]bool) error {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return fmt.Errorf("jq parsing: %w", err)
+	}
+
+	names := append([]string{}, builtinVariables...)
+	for name := range vars {
+		names = append(names, "$"+name)
+	}
+
+	_, err = gojq.Compile(parsed, gojq.WithVariables(names))
+	if err != nil {
+		return fmt.Errorf("jq compiling: %w", err)
+	}
+
+	return nil
+}
+
 // validate checks the ConfRule is valid
-func (r *ConfRule) validate(vars map[string]bool) (errs []error) {
+func (r *ConfRule) validate(globalVars map[string]bool) (errs []error) {
 	var err error
 
+	// rule-local variables shadow global variables
+	vars := map[string]bool{}
+	for varName := range globalVars {
+		vars[varName] = true
+	}
+
+	for varName, v := range r.Vars {
+		validationErrors := validateVariable(varName, v)
+
+		// Wrap all returned errors
+		for _, err2 := range validationErrors {
+			err = fmt.Errorf("vars: %w", err2)
+			errs = append(errs, err)
+		}
+
+		if len(validationErrors) == 0 {
+			vars[varName] = true
+		}
+	}
+
 	triggers := map[string]bool{}
 	for triggerName, trigger := range r.Triggers {
 		validationErrors := trigger.validate(vars)
@@ -187,13 +427,44 @@ func (a *ConfAction) validate(vars, triggers map[string]bool) (errs []error) {
 		}
 	}
 
+	if a.Delay != nil {
+		if d, ok := parseDelay(*a.Delay); !ok {
+			err = validateJQ(*a.Delay, vars)
+			if err != nil {
+				err = fmt.Errorf("delay: %w", err)
+				errs = append(errs, err)
+			}
+		} else if d < 0 {
+			err = fmt.Errorf("delay: negative delay %s", d)
+			errs = append(errs, err)
+		}
+	}
+
+	if !validFanOut(a.FanOut) {
+		err = fmt.Errorf("fan-out: unknown mode %q, expected %q or %q", a.FanOut, FanOutEach, FanOutZip)
+		errs = append(errs, err)
+	}
+
+	if a.MaxFanOut < 0 {
+		err = errors.New("max-fan-out: must not be negative")
+		errs = append(errs, err)
+	} else if a.MaxFanOut != 0 && a.FanOut == "" {
+		err = errors.New("max-fan-out: requires 'fan-out:'")
+		errs = append(errs, err)
+	}
+
+	if len(a.Cancel) != 0 && a.EventJQ == "" && a.DataJQ == "" {
+		// only cancels actions
+		return errs
+	}
+
 	if a.DataJQ == "" {
 		err = fmt.Errorf("'data:' must be set")
 		errs = append(errs, err)
 	}
-	_, err = gojq.Parse(a.DataJQ)
+	err = validateJQ(a.DataJQ, vars)
 	if err != nil {
-		err = fmt.Errorf("data: jq parsing: %w", err)
+		err = fmt.Errorf("data: %w", err)
 		errs = append(errs, err)
 	}
 
@@ -201,9 +472,9 @@ func (a *ConfAction) validate(vars, triggers map[string]bool) (errs []error) {
 		err = fmt.Errorf("'event:' must be set")
 		errs = append(errs, err)
 	}
-	_, err = gojq.Parse(a.EventJQ)
+	err = validateJQ(a.EventJQ, vars)
 	if err != nil {
-		err = fmt.Errorf("event: jq parsing: %w", err)
+		err = fmt.Errorf("event: %w", err)
 		errs = append(errs, err)
 	}
 
@@ -240,6 +511,28 @@ func (t *ConfTriggerCondition) validate(vars map[string]bool, triggers map[strin
 		errs = append(errs, err)
 	}
 
+	if t.JSONQuery != nil {
+		err = validateJQ(*t.JSONQuery, vars)
+		if err != nil {
+			err = fmt.Errorf("jq: %w", err)
+			errs = append(errs, err)
+		}
+	}
+
+	for i, cond := range t.AND {
+		for _, err2 := range cond.validate(vars, triggers) {
+			err = fmt.Errorf("and: %d: %w", i, err2)
+			errs = append(errs, err)
+		}
+	}
+
+	for i, cond := range t.OR {
+		for _, err2 := range cond.validate(vars, triggers) {
+			err = fmt.Errorf("or: %d: %w", i, err2)
+			errs = append(errs, err)
+		}
+	}
+
 	return
 }
 
@@ -256,12 +549,33 @@ func (t *ConfTrigger) validate(vars map[string]bool) (errs []error) {
 		}
 	}
 
-	err = validateEventString(*t.Event)
-	if err != nil {
-		err = fmt.Errorf("event: %w", err)
+	kinds := 0
+	for _, kind := range []*string{t.Event, t.Every, t.Cron} {
+		if kind != nil {
+			kinds++
+		}
+	}
+
+	if kinds != 1 {
+		err = errors.New("exactly one of 'event:', 'every:' or 'cron:' must be set")
 		errs = append(errs, err)
 	}
 
+	if t.Event != nil {
+		err = validateEventString(*t.Event)
+		if err != nil {
+			err = fmt.Errorf("event: %w", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if t.Every != nil || t.Cron != nil {
+		_, err = t.schedule()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	return errs
 }
 
@@ -283,6 +597,14 @@ func (vm *ConfVariableModifier) validate(varName string, vars map[string]bool) (
 		errs = append(errs, err)
 	}
 
+	if vm.JSONQuery != nil {
+		err = validateJQ(*vm.JSONQuery, vars)
+		if err != nil {
+			err = fmt.Errorf("set: %w", err)
+			errs = append(errs, err)
+		}
+	}
+
 	return errs
 }
 
@@ -0,0 +1,187 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the shorthands accepted in place of cron expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a parsed cron expression,
+// with the values each field matches.
+type cronSchedule struct {
+	minute map[int]bool
+	hour   map[int]bool
+	dom    map[int]bool
+	month  map[int]bool
+	dow    map[int]bool
+	// whether the day of month and day of week fields are not "*",
+	// in which case a day matches if either of them does
+	domRestricted bool
+	dowRestricted bool
+}
+
+// parseCron parses a standard five field cron expression in local time:
+// minute, hour, day of month, month and day of week.
+//
+// Fields may be "*", a number, a range such as "1-5", a list such as "1,3,5",
+// and may have a step such as "*/15". Sunday is 0 or 7.
+func parseCron(spec string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields but got %d", len(fields))
+	}
+
+	var err error
+	s := &cronSchedule{}
+	parsers := []struct {
+		name     string
+		min, max int
+		out      *map[int]bool
+	}{
+		{"minute", 0, 59, &s.minute},
+		{"hour", 0, 23, &s.hour},
+		{"day of month", 1, 31, &s.dom},
+		{"month", 1, 12, &s.month},
+		{"day of week", 0, 7, &s.dow},
+	}
+
+	for i, p := range parsers {
+		*p.out, err = parseCronField(fields[i], p.min, p.max)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+
+	if s.dow[7] {
+		s.dow[0] = true
+	}
+
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+
+	if s.next(time.Now()).IsZero() {
+		return nil, errors.New("schedule never fires")
+	}
+
+	return s, nil
+}
+
+// parseCronField returns the values a cron field matches.
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			loPart, hiPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			lo, err = strconv.Atoi(loPart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", loPart)
+			}
+
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(hiPart)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", hiPart)
+				}
+			} else if hasStep {
+				// "5/15" means every 15 starting at 5
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q is out of range %d-%d", rangePart, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// dayMatches returns whether the schedule fires on the day of t.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom[t.Day()]
+	dow := s.dow[int(t.Weekday())]
+
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// wallClock returns the time shown on a clock in the location of t,
+// which repeats when daylight saving time ends.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// next returns the first time after t the schedule fires,
+// or the zero time if it does not fire within five years.
+//
+// Times that do not exist because daylight saving time started are skipped,
+// and times that repeat because it ended only fire once.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	after := wallClock(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		var skipTo time.Time
+		switch {
+		case !s.month[int(t.Month())]:
+			skipTo = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			skipTo = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hour[t.Hour()]:
+			skipTo = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minute[t.Minute()], !wallClock(t).After(after):
+			skipTo = t.Add(time.Minute)
+		default:
+			return t
+		}
+
+		// times that do not exist may be normalized to an earlier time
+		if !skipTo.After(t) {
+			skipTo = t.Add(time.Minute)
+		}
+		t = skipTo
+	}
+
+	return time.Time{}
+}
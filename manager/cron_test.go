@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"too few fields", "* * *"},
+		{"too many fields", "* * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day of week out of range", "0 0 * * 8"},
+		{"reversed range", "5-1 * * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"not a number", "a * * * *"},
+		{"unknown macro", "@often"},
+		{"never fires", "0 0 30 2 *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCron(tt.spec); err == nil {
+				t.Errorf("parseCron(%q) succeeded, want an error", tt.spec)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utc := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+
+		return t
+	}
+
+	// times in New York, with the UTC offset to tell repeated times apart
+	ny := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04 -0700", s)
+		if err != nil {
+			panic(err)
+		}
+
+		return t.In(newYork)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", utc("2026-10-18 10:07"), utc("2026-10-18 10:08")},
+		{"not the same minute", "7 * * * *", utc("2026-10-18 10:07"), utc("2026-10-18 11:07")},
+		{"step", "*/15 * * * *", utc("2026-10-18 10:07"), utc("2026-10-18 10:15")},
+		{"step from value", "5/15 * * * *", utc("2026-10-18 10:07"), utc("2026-10-18 10:20")},
+		{"step in range", "10-40/20 * * * *", utc("2026-10-18 10:31"), utc("2026-10-18 11:10")},
+		{"list", "0 8,12,18 * * *", utc("2026-10-18 12:00"), utc("2026-10-18 18:00")},
+		{"weekdays from saturday", "0 9 * * 1-5", utc("2026-10-17 12:00"), utc("2026-10-19 09:00")},
+		{"7 is sunday", "0 0 * * 7", utc("2026-10-14 00:00"), utc("2026-10-18 00:00")},
+		{"0 is sunday", "0 0 * * 0", utc("2026-10-14 00:00"), utc("2026-10-18 00:00")},
+		{"day of month only", "0 0 13 * *", utc("2026-10-01 00:00"), utc("2026-10-13 00:00")},
+		{"day of month or week, week first", "0 0 13 * 5", utc("2026-10-01 00:00"), utc("2026-10-02 00:00")},
+		{"day of month or week, month first", "0 0 13 * 5", utc("2026-10-10 00:00"), utc("2026-10-13 00:00")},
+		{"skips short months", "0 0 31 * *", utc("2026-04-01 00:00"), utc("2026-05-31 00:00")},
+		{"leap day", "0 0 29 2 *", utc("2026-03-01 00:00"), utc("2028-02-29 00:00")},
+		{"year rollover", "0 0 1 1 *", utc("2026-10-18 00:00"), utc("2027-01-01 00:00")},
+		{"@hourly", "@hourly", utc("2026-10-18 10:07"), utc("2026-10-18 11:00")},
+		{"@daily", "@daily", utc("2026-10-18 10:07"), utc("2026-10-19 00:00")},
+		{"@weekly", "@weekly", utc("2026-10-18 10:07"), utc("2026-10-25 00:00")},
+		{"@monthly", "@monthly", utc("2026-10-18 10:07"), utc("2026-11-01 00:00")},
+		{"@yearly", "@yearly", utc("2026-10-18 10:07"), utc("2027-01-01 00:00")},
+		{"dst start skips missing time", "30 2 * * *", ny("2026-03-08 00:00 -0500"), ny("2026-03-09 02:30 -0400")},
+		{"dst start hourly", "0 * * * *", ny("2026-03-08 01:00 -0500"), ny("2026-03-08 03:00 -0400")},
+		{"dst end first time", "30 1 * * *", ny("2026-11-01 00:00 -0400"), ny("2026-11-01 01:30 -0400")},
+		{"dst end fires once", "30 1 * * *", ny("2026-11-01 01:30 -0400"), ny("2026-11-02 01:30 -0500")},
+		{"dst end hourly", "0 * * * *", ny("2026-11-01 01:00 -0400"), ny("2026-11-01 02:00 -0500")},
+		{"dst end every minute", "* * * * *", ny("2026-11-01 01:59 -0400"), ny("2026-11-01 02:00 -0500")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron(%q): %v", tt.spec, err)
+			}
+
+			got := s.next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
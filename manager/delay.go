@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PendingAction is a delayed action waiting to be performed.
+type PendingAction struct {
+	// The rule the action is part of.
+	Rule string `json:"rule"`
+	// The name of the action.
+	Action string `json:"action"`
+	// When the action will be performed.
+	At time.Time `json:"at"`
+
+	timer *time.Timer
+	// context of the evaluation that triggered the action
+	tctx *TriggerContext
+}
+
+// cancelKey returns the actionKey of an action named in a "cancel:" list,
+// which is in the rule unless it is named as "{rule}/{action}".
+func cancelKey(ruleName, name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+
+	return actionKey(ruleName, name)
+}
+
+// parseDelay returns the delay of an action if it is a duration,
+// and false if it is a jq query instead.
+func parseDelay(delay string) (time.Duration, bool) {
+	d, err := time.ParseDuration(strings.TrimSpace(delay))
+	return d, err == nil
+}
+
+// actionDelay returns how long to delay an action.
+//
+// The delay is a duration, or a jq query producing either a duration
+// or a number of seconds.
+func (m *Manager) actionDelay(delay string, tctx *TriggerContext) (time.Duration, error) {
+	if d, ok := parseDelay(delay); ok {
+		return d, nil
+	}
+
+	value, err := m.doJQ(delay, tctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var d time.Duration
+	switch value := value.(type) {
+	case string:
+		d, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+	case nil:
+		return 0, errors.New("delay is null")
+	default:
+		seconds, ok := toFloat(value)
+		if !ok {
+			return 0, fmt.Errorf("expected duration or number got %T for delay JQ", value)
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("negative delay %s", d)
+	}
+
+	return d, nil
+}
+
+// delayAction performs an action of the rule after a delay. If the action is
+// already pending it is restarted, with the context of the new evaluation.
+func (m *Manager) delayAction(actionName string, delay time.Duration, tctx *TriggerContext) {
+	key := actionKey(tctx.ruleName, actionName)
+	p := &PendingAction{
+		Rule:   tctx.ruleName,
+		Action: actionName,
+		At:     time.Now().Add(delay),
+		tctx:   tctx,
+	}
+
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+
+	if old, ok := m.pending[key]; ok {
+		old.timer.Stop()
+	}
+
+	m.pending[key] = p
+	p.timer = time.AfterFunc(delay, func() {
+		m.performPending(key, p)
+	})
+}
+
+// performPending performs a delayed action once its delay is over.
+func (m *Manager) performPending(key string, p *PendingAction) {
+	m.pendingMu.Lock()
+	if m.pending[key] != p {
+		// cancelled or restarted while the timer fired
+		m.pendingMu.Unlock()
+		return
+	}
+	delete(m.pending, key)
+	m.pendingMu.Unlock()
+
+	// the action as currently configured
+	m.mu.Lock()
+	config := m.Config
+	disabled := m.disabledRules[p.Rule] || m.disabledActions[key]
+	m.mu.Unlock()
+
+	action, ok := config.Rules[p.Rule].Actions[p.Action]
+	if !ok || disabled {
+		return
+	}
+
+	tctx := *p.tctx
+	tctx.config = config
+	tctx.actions = nil
+
+	err := m.update(&tctx, func() error {
+		return m.performAction(action, &tctx)
+	})
+	if err != nil {
+		log.Err(err).
+			Str("rule", p.Rule).
+			Str("action", p.Action).
+			Msg("delayed action failed")
+	}
+}
+
+// cancelRemoved cancels the pending actions that are not delayed actions
+// of the config, such as after they were removed from the config file.
+func (m *Manager) cancelRemoved(c *Config) {
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+
+	for key, p := range m.pending {
+		if c.Rules[p.Rule].Actions[p.Action].Delay == nil {
+			p.timer.Stop()
+			delete(m.pending, key)
+		}
+	}
+}
+
+// CancelAction cancels a pending delayed action,
+// returning whether it was pending.
+func (m *Manager) CancelAction(ruleName, actionName string) bool {
+	key := actionKey(ruleName, actionName)
+
+	m.pendingMu.Lock()
+	defer m.pendingMu.Unlock()
+
+	p, ok := m.pending[key]
+	if ok {
+		p.timer.Stop()
+		delete(m.pending, key)
+	}
+
+	return ok
+}
+
+// PendingActions returns the delayed actions waiting to be performed,
+// soonest first.
+func (m *Manager) PendingActions() []PendingAction {
+	m.pendingMu.Lock()
+	pending := make([]PendingAction, 0, len(m.pending))
+	for _, p := range m.pending {
+		pending = append(pending, PendingAction{
+			Rule:   p.Rule,
+			Action: p.Action,
+			At:     p.At,
+		})
+	}
+	m.pendingMu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].At.Before(pending[j].At)
+	})
+
+	return pending
+}
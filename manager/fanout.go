@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"fmt"
+)
+
+const (
+	// Broadcast an event for every combination of event URL and data.
+	FanOutEach = "each"
+	// Broadcast an event for each event URL and data at the same position.
+	FanOutZip = "zip"
+
+	// Maximum events an action fans out to, if the action does not set one.
+	defaultMaxFanOut = 100
+)
+
+// validFanOut returns whether mode is a fan-out mode.
+func validFanOut(mode string) bool {
+	return mode == "" || mode == FanOutEach || mode == FanOutZip
+}
+
+// fanOutPair is an event broadcast by an action.
+type fanOutPair struct {
+	event string
+	data  any
+}
+
+// fanOutAction broadcasts an event for the results of the event and data queries
+// according to the fan-out mode of the action.
+//
+// No events are broadcast if the action would fan out to more than its maximum.
+func (m *Manager) fanOutAction(action ConfAction, tctx *TriggerContext) error {
+	max := action.MaxFanOut
+	if max == 0 {
+		max = defaultMaxFanOut
+	}
+
+	// one more than allowed, to know when there are too many
+	events, err := m.doJQAll(action.EventJQ, tctx, max+1)
+	if err != nil {
+		return err
+	}
+
+	data, err := m.doJQAll(action.DataJQ, tctx, max+1)
+	if err != nil {
+		return err
+	}
+
+	pairs, err := fanOut(action.FanOut, events, data, max)
+	if err != nil {
+		return err
+	}
+
+	// Modify vars after doing all JQs
+	err = m.applyVariableModifiers(action.ModifyVars, tctx)
+	if err != nil {
+		return err
+	}
+
+	ctx := tctx.broadcastContext()
+	tctx.afterCommit(func() error {
+		for _, pair := range pairs {
+			err := m.Node.BroadcastAnyContext(ctx, pair.event, pair.data)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+// fanOut pairs event URLs with data, returning at most max pairs.
+func fanOut(mode string, events, data []any, max int) ([]fanOutPair, error) {
+	eventURLs := make([]string, len(events))
+	for i, e := range events {
+		eventURL, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string got %T for event JQ", e)
+		}
+		eventURLs[i] = eventURL
+	}
+
+	var pairs []fanOutPair
+	switch mode {
+	case FanOutEach:
+		if len(eventURLs)*len(data) > max {
+			return nil, fmt.Errorf("fan-out of more than %d events", max)
+		}
+
+		for _, eventURL := range eventURLs {
+			for _, d := range data {
+				pairs = append(pairs, fanOutPair{eventURL, d})
+			}
+		}
+	case FanOutZip:
+		if len(eventURLs) > max || len(data) > max {
+			return nil, fmt.Errorf("fan-out of more than %d events", max)
+		}
+
+		if len(eventURLs) != len(data) {
+			return nil, fmt.Errorf("cannot zip %d event URLs with %d data", len(eventURLs), len(data))
+		}
+
+		for i, eventURL := range eventURLs {
+			pairs = append(pairs, fanOutPair{eventURL, data[i]})
+		}
+	default:
+		return nil, fmt.Errorf("unknown fan-out %q", mode)
+	}
+
+	return pairs, nil
+}
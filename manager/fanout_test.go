@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/notnotquinn/wts"
+)
+
+func TestFanOut(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		events  []any
+		data    []any
+		max     int
+		want    []fanOutPair
+		wantErr bool
+	}{
+		{
+			name:   "each",
+			mode:   FanOutEach,
+			events: []any{"a", "b"},
+			data:   []any{1, 2},
+			max:    4,
+			want:   []fanOutPair{{"a", 1}, {"a", 2}, {"b", 1}, {"b", 2}},
+		},
+		{
+			name:   "zip",
+			mode:   FanOutZip,
+			events: []any{"a", "b"},
+			data:   []any{1, 2},
+			max:    2,
+			want:   []fanOutPair{{"a", 1}, {"b", 2}},
+		},
+		{
+			name:    "each over the cap",
+			mode:    FanOutEach,
+			events:  []any{"a", "b"},
+			data:    []any{1, 2},
+			max:     3,
+			wantErr: true,
+		},
+		{
+			name:    "zip over the cap",
+			mode:    FanOutZip,
+			events:  []any{"a", "b", "c"},
+			data:    []any{1, 2, 3},
+			max:     2,
+			wantErr: true,
+		},
+		{
+			name:    "zip of different lengths",
+			mode:    FanOutZip,
+			events:  []any{"a", "b"},
+			data:    []any{1},
+			max:     2,
+			wantErr: true,
+		},
+		{
+			name:   "each with no events",
+			mode:   FanOutEach,
+			events: nil,
+			data:   []any{1, 2},
+			max:    2,
+			want:   nil,
+		},
+		{
+			name:   "each with no data",
+			mode:   FanOutEach,
+			events: []any{"a"},
+			data:   nil,
+			max:    2,
+			want:   nil,
+		},
+		{
+			name:   "zip with no results",
+			mode:   FanOutZip,
+			events: nil,
+			data:   nil,
+			max:    2,
+			want:   nil,
+		},
+		{
+			name:   "zip keeps null data in place",
+			mode:   FanOutZip,
+			events: []any{"a", "b", "c"},
+			data:   []any{1, nil, 3},
+			max:    3,
+			want:   []fanOutPair{{"a", 1}, {"b", nil}, {"c", 3}},
+		},
+		{
+			name:    "null event URL",
+			mode:    FanOutEach,
+			events:  []any{"a", nil},
+			data:    []any{1},
+			max:     2,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fanOut(tt.mode, tt.events, tt.data, tt.max)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fanOut() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fanOut() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoJQAll(t *testing.T) {
+	m := &Manager{}
+	tctx := &TriggerContext{
+		message: &wts.EventPayload[any]{Data: map[string]any{
+			"devices": []any{},
+			"values":  []any{float64(1), nil, float64(3)},
+		}},
+		config: &Config{},
+		vars:   &VariableTx{values: map[string]any{}},
+	}
+
+	tests := []struct {
+		name  string
+		jq    string
+		limit int
+		want  []any
+	}{
+		{"empty list", ".devices[]", 10, nil},
+		{"nulls are kept", ".values[]", 10, []any{float64(1), nil, float64(3)}},
+		{"limited", ".values[]", 2, []any{float64(1), nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.doJQAll(tt.jq, tctx, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("doJQAll(%q) = %v, want %v", tt.jq, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package manager
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/itchyny/gojq"
 	"github.com/notnotquinn/go-websub"
@@ -16,13 +20,40 @@ var log = websub.Logger()
 
 type Manager struct {
 	*websub.Hub
-	Node          *wts.Node
-	Config        *Config
-	VariableState map[string]string
-	mux           *http.ServeMux
+	Node      *wts.Node
+	Config    *Config
+	Variables *VariableStore
+	mux       *http.ServeMux
+	// path the config was loaded from
+	configPath string
+	// maps event URL to the hook handling it
+	hooks map[string]hookRemover
+	// Config and hooks mutex
+	mu *sync.Mutex
+	// stores persisted variables, nil for the file set in the config
+	backend VariableBackend
+	// rules disabled through the admin API
+	disabledRules map[string]bool
+	// actions disabled through the admin API, by actionKey
+	disabledActions map[string]bool
+	// the most recent rule evaluations, oldest first
+	evaluations []RuleEvaluation
+	// evaluations mutex
+	evaluationsMu *sync.Mutex
+	// closed to stop the scheduled triggers of the current config
+	stopSchedules chan struct{}
+	// delayed actions waiting to be performed, by actionKey
+	pending map[string]*PendingAction
+	// pending actions mutex
+	pendingMu *sync.Mutex
 }
 
-func New(configPath string) (*Manager, []error) {
+// hookRemover is a hook added to the manager's node.
+type hookRemover interface {
+	Remove() error
+}
+
+func New(configPath string, options ...ManagerOption) (*Manager, []error) {
 	c := &Config{}
 	if err := c.Load(configPath); err != nil {
 		return nil, err
@@ -44,100 +75,211 @@ func New(configPath string) (*Manager, []error) {
 			websub.HubWithUserAgent("wts-manager-hub"),
 			websub.HubWithHashFunction("sha512"),
 		),
-		Node:          wts.NewNode(nodeBase, hubBase),
-		VariableState: map[string]string{},
-		Config:        c,
-		mux:           http.NewServeMux(),
+		Node:            wts.NewNode(nodeBase, hubBase),
+		Variables:       NewVariableStore(c.initialValues()),
+		Config:          c,
+		mux:             http.NewServeMux(),
+		configPath:      configPath,
+		hooks:           make(map[string]hookRemover),
+		mu:              &sync.Mutex{},
+		disabledRules:   make(map[string]bool),
+		disabledActions: make(map[string]bool),
+		evaluationsMu:   &sync.Mutex{},
+		pending:         make(map[string]*PendingAction),
+		pendingMu:       &sync.Mutex{},
+	}
+
+	for _, opt := range options {
+		opt(m)
+	}
+
+	err := m.Variables.Persist(m.variableBackend(), c.persistedVariables())
+	if err != nil {
+		return nil, []error{err}
 	}
 
-	// Store initial values
-	for k, v := range m.Config.InitialVars {
-		m.VariableState[k] = v
+	err = m.resetMistyped(c)
+	if err != nil {
+		return nil, []error{err}
 	}
 
 	m.mux.Handle(hubPath, http.StripPrefix(strings.TrimSuffix(hubPath, "/"), m.Hub))
 	m.mux.Handle(nodePath, http.StripPrefix(strings.TrimSuffix(nodePath, "/"), m.Node))
+	m.mux.Handle(adminPath, http.StripPrefix(strings.TrimSuffix(adminPath, "/"), http.HandlerFunc(m.handleAdmin)))
 
-	err := m.registerEventHooks()
+	err = m.registerEventHooks()
 	if err != nil {
 		return nil, []error{err}
 	}
 
+	m.startSchedules()
+
 	return m, nil
 }
 
-func (m *Manager) registerEventHooks() error {
-	// find all entities and associated events to subscribe to
-	var eventsMap = make(map[string]map[wts.EventType]bool)
-	for _, rule := range m.Config.Rules {
+// triggerEvents returns the event URLs that trigger the rules of a config.
+func triggerEvents(c *Config) (map[string]bool, error) {
+	events := make(map[string]bool)
+	for _, rule := range c.Rules {
 		for _, trigger := range rule.Triggers {
 			if trigger.Event != nil {
 				eventTrimmed := strings.TrimRight(*trigger.Event, "/")
-				entityURL, eventType, err := wts.ParseEventURL(eventTrimmed)
+				_, _, err := wts.ParseEventURL(eventTrimmed)
 				if err != nil {
-					return err
+					return nil, err
 				}
 
-				if _, ok := eventsMap[entityURL]; !ok {
-					eventsMap[entityURL] = map[wts.EventType]bool{
-						eventType: true,
-					}
-				} else {
-					eventsMap[entityURL][eventType] = true
-				}
+				events[eventTrimmed] = true
 			}
 		}
 	}
 
-	// subscribe to events through the node
-	for entityURL, events := range eventsMap {
-		var onRequest, onExecuted, onData wts.OnEventFunc[any] = nil, nil, nil
-		if events[wts.Request] {
-			onRequest = m.handleEvents
+	return events, nil
+}
+
+// registerEventHooks adds hooks for the events that trigger rules, and removes
+// hooks for events that no longer do. The manager must be locked.
+func (m *Manager) registerEventHooks() error {
+	events, err := triggerEvents(m.Config)
+	if err != nil {
+		return err
+	}
+
+	for eventURL, hook := range m.hooks {
+		if events[eventURL] {
+			continue
 		}
-		if events[wts.Executed] {
-			onExecuted = m.handleEvents
+
+		err := hook.Remove()
+		if err != nil {
+			return err
 		}
-		if events[wts.Data] {
-			onData = m.handleEvents
+		delete(m.hooks, eventURL)
+	}
+
+	// subscribe to events through the node
+	for eventURL := range events {
+		if _, exists := m.hooks[eventURL]; exists {
+			continue
 		}
 
-		if onExecuted != nil || onRequest != nil {
-			_, err := wts.AddActorHook(m.Node, entityURL, onRequest, onExecuted)
-			if err != nil {
-				return err
-			}
+		entityURL, eventType, _ := wts.ParseEventURL(eventURL)
+
+		var hook hookRemover
+		switch eventType {
+		case wts.Request:
+			hook, err = wts.AddActorHook(m.Node, entityURL, m.handleEvents, nil)
+		case wts.Executed:
+			hook, err = wts.AddActorHook(m.Node, entityURL, nil, m.handleEvents)
+		case wts.Data:
+			hook, err = wts.AddEmitterHook(m.Node, entityURL, m.handleEvents)
+		default:
+			continue
 		}
 
-		if onData != nil {
-			_, err := wts.AddEmitterHook(m.Node, entityURL, onData)
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return err
 		}
+		m.hooks[eventURL] = hook
 	}
 
 	return nil
 }
 
 func (m *Manager) handleEvents(eventURL string, msg *wts.EventPayload[any]) {
-	for ruleName, rule := range m.Config.Rules {
-		for triggerName, trigger := range rule.Triggers {
-			trimmedEventURL := strings.TrimRight(eventURL, "/")
+	m.mu.Lock()
+	config := m.Config
+	disabledRules := copySet(m.disabledRules)
+	disabledActions := copySet(m.disabledActions)
+	m.mu.Unlock()
+
+	trimmedEventURL := strings.TrimRight(eventURL, "/")
+
+	for _, ruleName := range config.ruleNames {
+		if disabledRules[ruleName] {
+			continue
+		}
+
+		rule := config.Rules[ruleName]
+		triggered := false
+
+		for _, triggerName := range config.triggerNames[ruleName] {
+			trigger := rule.Triggers[triggerName]
 			if trigger.Event != nil && *trigger.Event == trimmedEventURL {
-				err := m.ruleTriggered(&TriggerContext{
-					ruleName:    ruleName,
-					triggerName: triggerName,
-					message:     msg,
+				err := m.evaluateRule(&TriggerContext{
+					ruleName:        ruleName,
+					triggerName:     triggerName,
+					eventURL:        trimmedEventURL,
+					message:         msg,
+					config:          config,
+					disabledActions: disabledActions,
 				})
-				log.Err(err)
+				if err != nil {
+					log.Err(err).
+						Str("rule", ruleName).
+						Str("trigger", triggerName).
+						Msg("could not evaluate rule")
+				} else {
+					triggered = true
+				}
 			}
 		}
+
+		if triggered && rule.Stop {
+			break
+		}
 	}
 }
 
+// evaluateRule runs a triggered rule, and records the evaluation.
+func (m *Manager) evaluateRule(tctx *TriggerContext) error {
+	started := time.Now()
+
+	err := m.update(tctx, func() error {
+		return m.ruleTriggered(tctx)
+	})
+
+	m.recordEvaluation(tctx, started, err)
+	return err
+}
+
+// update runs fn in a variable transaction for the rule, then performs the
+// side effects queued with afterCommit once the changes are committed.
+//
+// Each rule sees and modifies the variables atomically, and its events are only
+// broadcast if its changes are committed. Side effects run outside the
+// transaction, so slow hubs do not hold up other rules.
+func (m *Manager) update(tctx *TriggerContext, fn func() error) error {
+	err := m.Variables.Update(func(tx *VariableTx) error {
+		tctx.vars = tx
+		tctx.effects = nil
+		return fn()
+	})
+	if err != nil {
+		return err
+	}
+
+	// every side effect is performed, and the first error returned
+	for _, effect := range tctx.effects {
+		err2 := effect()
+		if err2 == nil {
+			continue
+		}
+
+		if err == nil {
+			err = err2
+		} else {
+			log.Err(err2).
+				Str("rule", tctx.ruleName).
+				Msg("side effect of rule failed")
+		}
+	}
+
+	return err
+}
+
 func (m *Manager) ruleTriggered(tctx *TriggerContext) error {
-	if rule, ok := m.Config.Rules[tctx.ruleName]; ok {
+	if rule, ok := tctx.config.Rules[tctx.ruleName]; ok {
 		if trigger, ok := rule.Triggers[tctx.triggerName]; ok {
 			err := m.applyVariableModifiers(trigger.ModifyVars, tctx)
 			if err != nil {
@@ -145,15 +287,21 @@ func (m *Manager) ruleTriggered(tctx *TriggerContext) error {
 			}
 		}
 
-		for _, action := range rule.Actions {
+		for _, actionName := range tctx.config.actionNames[tctx.ruleName] {
+			action := rule.Actions[actionName]
+			if tctx.disabledActions[actionKey(tctx.ruleName, actionName)] {
+				continue
+			}
+
 			cond, err := m.checkTriggerCondition(action.TriggerCondition, tctx)
 			if err != nil {
 				return err
 			} else if cond {
-				err := m.actionTriggered(action, tctx)
+				err := m.actionTriggered(actionName, action, tctx)
 				if err != nil {
 					return err
 				}
+				tctx.actions = append(tctx.actions, actionName)
 			}
 		}
 	}
@@ -162,8 +310,18 @@ func (m *Manager) ruleTriggered(tctx *TriggerContext) error {
 }
 
 func (m *Manager) applyVariableModifiers(modifiers map[string]ConfVariableModifier, tctx *TriggerContext) error {
-	for variableName, modifier := range modifiers {
-		if _, ok := m.VariableState[variableName]; !ok {
+	// in a fixed order, as modifiers may read variables modified before them
+	variableNames := make([]string, 0, len(modifiers))
+	for variableName := range modifiers {
+		variableNames = append(variableNames, variableName)
+	}
+	sort.Strings(variableNames)
+
+	for _, variableName := range variableNames {
+		modifier := modifiers[variableName]
+		key := tctx.variableKey(variableName)
+		variable, declared := tctx.config.variables()[key]
+		if _, ok := tctx.vars.Get(key); !ok || !declared {
 			return fmt.Errorf("variable %q does not exist", variableName)
 		}
 
@@ -173,15 +331,16 @@ func (m *Manager) applyVariableModifiers(modifiers map[string]ConfVariableModifi
 				return err
 			}
 
-			if str, ok := value.(string); ok {
-				m.VariableState[variableName] = str
-			} else {
-				return fmt.Errorf("expected string but got type %T", value)
+			err = variable.Type.check(value)
+			if err != nil {
+				return fmt.Errorf("variable %q: %w", variableName, err)
 			}
+
+			tctx.vars.Set(key, value)
 		}
 
 		if modifier.Reset != nil && *modifier.Reset {
-			m.VariableState[variableName] = m.Config.InitialVars[variableName]
+			tctx.vars.Set(key, variable.Value)
 		}
 	}
 
@@ -189,6 +348,53 @@ func (m *Manager) applyVariableModifiers(modifiers map[string]ConfVariableModifi
 }
 
 func (m *Manager) doJQ(jq string, tctx *TriggerContext) (value any, err error) {
+	iter, err := m.runJQ(jq, tctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return nil, errors.New("jq returned no value")
+		}
+
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+
+		if v != nil {
+			return v, nil
+		}
+	}
+}
+
+// doJQAll returns up to limit outputs of a query, including nulls.
+// Unlike doJQ, it is not an error for the query to return nothing.
+func (m *Manager) doJQAll(jq string, tctx *TriggerContext, limit int) (values []any, err error) {
+	iter, err := m.runJQ(jq, tctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(values) < limit {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// runJQ runs a query on the message of tctx, with the variables as jq variables.
+func (m *Manager) runJQ(jq string, tctx *TriggerContext) (gojq.Iter, error) {
 	query, err := gojq.Parse(jq)
 	if err != nil {
 		return nil, err
@@ -207,18 +413,23 @@ func (m *Manager) doJQ(jq string, tctx *TriggerContext) (value any, err error) {
 		return nil, err
 	}
 
-	// get variables (must be map to any, but only strings are used)
+	// get variables (must be map to any)
 	variables := map[string]any{
 		"$_ruleName":    tctx.ruleName,
 		"$_triggerName": tctx.triggerName,
 		"$_msg":         messageAsAny,
 	}
-	for varName, value := range m.VariableState {
+	for varName := range tctx.config.InitialVars {
 		if strings.HasPrefix(varName, "_") {
 			return nil, fmt.Errorf("variable must not start with '_'. (variable %q)", varName)
 		}
 
-		variables["$"+varName] = value
+		variables["$"+varName], _ = tctx.vars.Get(varName)
+	}
+
+	// rule-local variables shadow global variables
+	for varName := range tctx.config.Rules[tctx.ruleName].Vars {
+		variables["$"+varName], _ = tctx.vars.Get(localVariableKey(tctx.ruleName, varName))
 	}
 
 	// split variables into names/values
@@ -237,21 +448,7 @@ func (m *Manager) doJQ(jq string, tctx *TriggerContext) (value any, err error) {
 	}
 
 	// run with variable values
-	iter := code.Run(tctx.message.Data, variableValues...)
-	for {
-		v, ok := iter.Next()
-		if !ok {
-			return nil, errors.New("jq returned no value")
-		}
-
-		if err, ok := v.(error); ok {
-			return nil, err
-		}
-
-		if v != nil {
-			return v, nil
-		}
-	}
+	return code.Run(tctx.message.Data, variableValues...), nil
 }
 
 func (m *Manager) checkTriggerCondition(cond *ConfTriggerCondition, tctx *TriggerContext) (bool, error) {
@@ -337,7 +534,51 @@ func (m *Manager) checkTriggerCondition(cond *ConfTriggerCondition, tctx *Trigge
 	}
 }
 
-func (m *Manager) actionTriggered(action ConfAction, tctx *TriggerContext) error {
+// actionTriggered performs an action, or delays it if it has a delay.
+func (m *Manager) actionTriggered(actionName string, action ConfAction, tctx *TriggerContext) error {
+	if action.Delay != nil {
+		delay, err := m.actionDelay(*action.Delay, tctx)
+		if err != nil {
+			return fmt.Errorf("delay: %w", err)
+		}
+
+		if delay > 0 {
+			// only scheduled if the rule's changes are committed
+			tctx.afterCommit(func() error {
+				m.delayAction(actionName, delay, tctx)
+				return nil
+			})
+			return nil
+		}
+	}
+
+	return m.performAction(action, tctx)
+}
+
+// performAction cancels the pending actions named by the action,
+// and broadcasts its event.
+func (m *Manager) performAction(action ConfAction, tctx *TriggerContext) error {
+	if len(action.Cancel) != 0 {
+		// only cancelled if the rule's changes are committed
+		tctx.afterCommit(func() error {
+			for _, name := range action.Cancel {
+				ruleName, actionName, _ := strings.Cut(cancelKey(tctx.ruleName, name), "/")
+				m.CancelAction(ruleName, actionName)
+			}
+
+			return nil
+		})
+	}
+
+	if action.EventJQ == "" {
+		// only cancels actions
+		return m.applyVariableModifiers(action.ModifyVars, tctx)
+	}
+
+	if action.FanOut != "" {
+		return m.fanOutAction(action, tctx)
+	}
+
 	eventJQResult, err := m.doJQ(action.EventJQ, tctx)
 	if err != nil {
 		return err
@@ -359,10 +600,10 @@ func (m *Manager) actionTriggered(action ConfAction, tctx *TriggerContext) error
 		return err
 	}
 
-	err = m.Node.BroadcastAny(event, data)
-	if err != nil {
-		return err
-	}
+	ctx := tctx.broadcastContext()
+	tctx.afterCommit(func() error {
+		return m.Node.BroadcastAnyContext(ctx, event, data)
+	})
 
 	return nil
 }
@@ -376,4 +617,38 @@ type TriggerContext struct {
 	ruleName    string
 	triggerName string
 	message     *wts.EventPayload[any]
+	// event URL the message was received on
+	eventURL string
+	// config the rule is from
+	config *Config
+	// variables as seen by the rule
+	vars *VariableTx
+	// actions that are not performed, by actionKey
+	disabledActions map[string]bool
+	// names of the actions performed
+	actions []string
+	// side effects performed once the variable changes are committed
+	effects []func() error
+}
+
+// afterCommit queues a side effect, such as broadcasting an event,
+// to be performed once the variable changes of the rule are committed.
+func (tctx *TriggerContext) afterCommit(effect func() error) {
+	tctx.effects = append(tctx.effects, effect)
+}
+
+// broadcastContext returns the context events are broadcast with,
+// which links them to the message that triggered the rule.
+func (tctx *TriggerContext) broadcastContext() context.Context {
+	return wts.ContextWithEvent(context.Background(), tctx.message)
+}
+
+// variableKey returns the key a variable referenced by the rule is stored under,
+// which is the rule-local variable's if the rule has one with the name.
+func (tctx *TriggerContext) variableKey(name string) string {
+	if _, local := tctx.config.Rules[tctx.ruleName].Vars[name]; local {
+		return localVariableKey(tctx.ruleName, name)
+	}
+
+	return name
 }
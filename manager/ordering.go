@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ordering is where a rule or action is evaluated relative to its siblings.
+type ordering struct {
+	priority int
+	// names evaluated before this one
+	after []string
+}
+
+// order returns the names in the order they are evaluated.
+//
+// Names are evaluated after the names they list in "after:", and otherwise
+// highest priority first, then alphabetically.
+func order(items map[string]ordering) ([]string, error) {
+	for name, item := range items {
+		for _, before := range item.after {
+			if _, ok := items[before]; !ok {
+				return nil, fmt.Errorf("%q: after: %q does not exist", name, before)
+			}
+		}
+	}
+
+	ordered := make([]string, 0, len(items))
+	done := make(map[string]bool, len(items))
+
+	for len(ordered) < len(items) {
+		var next string
+		found := false
+
+		for name, item := range items {
+			if done[name] || !allDone(item.after, done) {
+				continue
+			}
+
+			if !found || item.priority > items[next].priority ||
+				(item.priority == items[next].priority && name < next) {
+				next = name
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("ordering cycle between %s", cycleNames(items, done))
+		}
+
+		ordered = append(ordered, next)
+		done[next] = true
+	}
+
+	return ordered, nil
+}
+
+func allDone(names []string, done map[string]bool) bool {
+	for _, name := range names {
+		if !done[name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cycleNames lists the names that could not be ordered.
+func cycleNames(items map[string]ordering, done map[string]bool) string {
+	var names []string
+	for name := range items {
+		if !done[name] {
+			names = append(names, fmt.Sprintf("%q", name))
+		}
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ruleOrder returns the names of the rules in the order they are evaluated.
+func (c *Config) ruleOrder() ([]string, error) {
+	items := make(map[string]ordering, len(c.Rules))
+	for name, rule := range c.Rules {
+		items[name] = ordering{rule.Priority, rule.After}
+	}
+
+	return order(items)
+}
+
+// actionOrder returns the names of the actions in the order they are performed.
+func (r *ConfRule) actionOrder() ([]string, error) {
+	items := make(map[string]ordering, len(r.Actions))
+	for name, action := range r.Actions {
+		items[name] = ordering{action.Priority, action.After}
+	}
+
+	return order(items)
+}
+
+// triggerOrder returns the names of the triggers, sorted.
+func (r *ConfRule) triggerOrder() []string {
+	names := make([]string, 0, len(r.Triggers))
+	for name := range r.Triggers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
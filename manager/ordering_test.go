@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   map[string]ordering
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			items: map[string]ordering{},
+			want:  []string{},
+		},
+		{
+			name: "alphabetical",
+			items: map[string]ordering{
+				"c": {}, "a": {}, "b": {},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "highest priority first",
+			items: map[string]ordering{
+				"a": {priority: -1}, "b": {}, "c": {priority: 5},
+			},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "after overrides priority",
+			items: map[string]ordering{
+				"a": {priority: 10, after: []string{"b"}},
+				"b": {},
+				"c": {priority: 5},
+			},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "chain",
+			items: map[string]ordering{
+				"a": {after: []string{"b"}},
+				"b": {after: []string{"c"}},
+				"c": {},
+			},
+			want: []string{"c", "b", "a"},
+		},
+		{
+			name: "missing name",
+			items: map[string]ordering{
+				"a": {after: []string{"missing"}},
+			},
+			wantErr: `after: "missing" does not exist`,
+		},
+		{
+			name: "self cycle",
+			items: map[string]ordering{
+				"a": {after: []string{"a"}},
+				"b": {},
+			},
+			wantErr: `ordering cycle between "a"`,
+		},
+		{
+			name: "cycle",
+			items: map[string]ordering{
+				"a": {after: []string{"c"}},
+				"b": {after: []string{"a"}},
+				"c": {after: []string{"b"}},
+				"d": {},
+			},
+			wantErr: `ordering cycle between "a", "b", "c"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := order(tt.items)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("order() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
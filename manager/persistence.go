@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// VariableBackend stores the values of persisted variables.
+type VariableBackend interface {
+	// Load returns the stored values.
+	Load() (map[string]any, error)
+	// Save replaces the stored values.
+	Save(values map[string]any) error
+}
+
+// FileBackend stores the values of persisted variables in a JSON file.
+type FileBackend struct {
+	// The file the values are stored in.
+	Path string
+}
+
+// NewFileBackend creates a backend that stores values in the JSON file at path.
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{Path: path}
+}
+
+// Load returns the stored values, which are empty if the file does not exist.
+func (b *FileBackend) Load() (map[string]any, error) {
+	content, err := os.ReadFile(b.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]any{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	err = json.Unmarshal(content, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// Save replaces the stored values.
+func (b *FileBackend) Save(values map[string]any) error {
+	content, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+
+	// write then rename, so a crash never leaves a partial file
+	err = os.WriteFile(b.Path+".tmp", content, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(b.Path+".tmp", b.Path)
+}
+
+// ManagerOption configures a Manager created with New.
+type ManagerOption func(m *Manager)
+
+// WithVariableBackend sets where persisted variables are stored,
+// instead of the file set in the config.
+func WithVariableBackend(backend VariableBackend) ManagerOption {
+	return func(m *Manager) {
+		m.backend = backend
+	}
+}
+
+// variableBackend returns the backend persisted variables are stored in.
+func (m *Manager) variableBackend() VariableBackend {
+	if m.backend != nil {
+		return m.backend
+	}
+
+	path := m.Config.VariablesFile
+	if path == "" {
+		path = m.configPath + ".vars.json"
+	}
+
+	return NewFileBackend(path)
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reload loads the config file again, and replaces the rules of the manager if
+// it is valid. Variables that still exist keep their values.
+//
+// The base URL and port can not be changed without restarting.
+func (m *Manager) Reload() []error {
+	c := &Config{}
+	if errs := c.Load(m.configPath); len(errs) != 0 {
+		return errs
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c.BaseURL != m.Config.BaseURL || c.HubPort != m.Config.HubPort {
+		log.Warn().Msg("base URL and port changes are ignored until the manager restarts")
+		c.BaseURL = m.Config.BaseURL
+		c.HubPort = m.Config.HubPort
+	}
+
+	old := m.Config
+	oldVariables := m.Variables.state()
+	m.Config = c
+
+	// restores the old config and variables if the new config can not be applied
+	rollback := func(err error) []error {
+		m.Config = old
+		m.Variables.restore(oldVariables)
+		if err2 := m.registerEventHooks(); err2 != nil {
+			return []error{err, err2}
+		}
+
+		return []error{err}
+	}
+
+	err := m.registerEventHooks()
+	if err != nil {
+		return rollback(err)
+	}
+
+	m.Variables.Declare(c.initialValues())
+	err = m.Variables.Persist(m.variableBackend(), c.persistedVariables())
+	if err != nil {
+		return rollback(err)
+	}
+
+	err = m.resetMistyped(c)
+	if err != nil {
+		return rollback(err)
+	}
+
+	// the reload succeeded, so the schedules and delays of the new config apply
+	m.startSchedules()
+	m.cancelRemoved(c)
+
+	log.Info().
+		Str("path", m.configPath).
+		Msg("reloaded config")
+
+	return nil
+}
+
+// WatchConfig reloads the config file when it changes, checking every interval,
+// and when the process receives SIGHUP. Invalid configs are logged and ignored.
+//
+// Stops watching once stop is called.
+func (m *Manager) WatchConfig(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		lastMod := m.configModTime()
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+			case <-t.C:
+				modTime := m.configModTime()
+				if modTime.Equal(lastMod) {
+					continue
+				}
+				lastMod = modTime
+			}
+
+			errs := m.Reload()
+			for _, err := range errs {
+				log.Err(err).
+					Str("path", m.configPath).
+					Msg("could not reload config")
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// configModTime returns when the config file was last modified,
+// or the zero time if it can not be read.
+func (m *Manager) configModTime() time.Time {
+	info, err := os.Stat(m.configPath)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// failingBackend is a VariableBackend whose Load fails once fail is set.
+type failingBackend struct {
+	fail bool
+}
+
+func (b *failingBackend) Load() (map[string]any, error) {
+	if b.fail {
+		return nil, errors.New("backend unavailable")
+	}
+
+	return map[string]any{}, nil
+}
+
+func (b *failingBackend) Save(values map[string]any) error {
+	return nil
+}
+
+func TestReloadRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.yaml")
+	writeConfig := func(config string) {
+		err := os.WriteFile(path, []byte(config), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("baseURL: http://localhost:9200/\nvars:\n  old: 1\n")
+	backend := &failingBackend{}
+	m, errs := New(path, WithVariableBackend(backend))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	old := m.Config
+	writeConfig("baseURL: http://localhost:9200/\nvars:\n  new: {value: 2, persist: true}\n")
+	backend.fail = true
+
+	if errs := m.Reload(); len(errs) == 0 {
+		t.Fatal("Reload succeeded, want the backend error")
+	}
+
+	if m.Config != old {
+		t.Error("config was not rolled back")
+	}
+
+	want := map[string]any{"old": float64(1)}
+	if got := m.Variables.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("variables = %v, want %v", got, want)
+	}
+}
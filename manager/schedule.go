@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/notnotquinn/wts"
+)
+
+// schedule decides when a scheduled trigger fires.
+type schedule interface {
+	// next returns the first time after t the schedule fires,
+	// or the zero time if it never does.
+	next(t time.Time) time.Time
+}
+
+// interval is a schedule that fires repeatedly with a fixed time between.
+type interval time.Duration
+
+func (i interval) next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// schedule returns the schedule of the trigger, nil if it is not scheduled.
+func (t *ConfTrigger) schedule() (schedule, error) {
+	switch {
+	case t.Every != nil:
+		d, err := time.ParseDuration(*t.Every)
+		if err != nil {
+			return nil, fmt.Errorf("every: %w", err)
+		}
+
+		if d <= 0 {
+			return nil, errors.New("every: interval must be positive")
+		}
+
+		return interval(d), nil
+	case t.Cron != nil:
+		s, err := parseCron(*t.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("cron: %w", err)
+		}
+
+		return s, nil
+	}
+
+	return nil, nil
+}
+
+// startSchedules starts firing the scheduled triggers of the config,
+// and stops the schedules of the previous config. The manager must be locked.
+func (m *Manager) startSchedules() {
+	if m.stopSchedules != nil {
+		close(m.stopSchedules)
+	}
+
+	stop := make(chan struct{})
+	m.stopSchedules = stop
+
+	for ruleName, rule := range m.Config.Rules {
+		for triggerName, trigger := range rule.Triggers {
+			s, err := trigger.schedule()
+			if err != nil || s == nil {
+				// not scheduled, the config has already been validated
+				continue
+			}
+
+			go m.runSchedule(ruleName, triggerName, s, stop)
+		}
+	}
+}
+
+// runSchedule fires a trigger according to its schedule until stop is closed.
+func (m *Manager) runSchedule(ruleName, triggerName string, s schedule, stop <-chan struct{}) {
+	last := time.Now()
+	for {
+		fireAt := s.next(last)
+		if fireAt.IsZero() {
+			return
+		}
+
+		// skip times missed while the rule was being evaluated
+		if now := time.Now(); fireAt.Before(now) {
+			fireAt = s.next(now)
+		}
+
+		timer := time.NewTimer(time.Until(fireAt))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		m.fireSchedule(ruleName, triggerName, fireAt, stop)
+		last = fireAt
+	}
+}
+
+// fireSchedule triggers a rule from a scheduled trigger,
+// with a message containing the time it fired.
+func (m *Manager) fireSchedule(ruleName, triggerName string, fireTime time.Time, stop <-chan struct{}) {
+	m.mu.Lock()
+	select {
+	case <-stop:
+		// the config was reloaded while waiting for the lock
+		m.mu.Unlock()
+		return
+	default:
+	}
+
+	config := m.Config
+	disabled := m.disabledRules[ruleName]
+	disabledActions := copySet(m.disabledActions)
+	m.mu.Unlock()
+
+	if disabled {
+		return
+	}
+
+	msg := &wts.EventPayload[any]{
+		ID: uuid.NewString(),
+		Data: map[string]any{
+			"time":    fireTime.Format(time.RFC3339),
+			"unix":    fireTime.Unix(),
+			"trigger": triggerName,
+		},
+		DateSent: fireTime,
+		Sender:   m.Node.BaseURL(),
+	}
+
+	err := m.evaluateRule(&TriggerContext{
+		ruleName:        ruleName,
+		triggerName:     triggerName,
+		message:         msg,
+		config:          config,
+		disabledActions: disabledActions,
+	})
+	if err != nil {
+		log.Err(err).
+			Str("rule", ruleName).
+			Str("trigger", triggerName).
+			Msg("scheduled trigger failed")
+	}
+}
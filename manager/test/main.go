@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/notnotquinn/go-websub"
 	"github.com/notnotquinn/wts/manager"
@@ -52,6 +53,9 @@ func main() {
 
 	go http.ListenAndServe(fmt.Sprintf(":%d", m.Config.HubPort), m)
 
+	stop := m.WatchConfig(time.Second)
+	defer stop()
+
 	err := m.Node.SubscribeAll()
 	if err != nil {
 		panic(err)
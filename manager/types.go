@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// VariableType is the type of JSON value a variable holds.
+type VariableType string
+
+const (
+	// Any JSON value. Used for variables without a declared type.
+	TypeAny VariableType = "any"
+	// A JSON string.
+	TypeString VariableType = "string"
+	// A JSON number.
+	TypeNumber VariableType = "number"
+	// A JSON number without a fractional part.
+	TypeInteger VariableType = "integer"
+	// true or false.
+	TypeBoolean VariableType = "boolean"
+	// A JSON array.
+	TypeArray VariableType = "array"
+	// A JSON object.
+	TypeObject VariableType = "object"
+)
+
+// zeroValue returns the value of variables of the type that have no initial value.
+func (t VariableType) zeroValue() any {
+	switch t {
+	case TypeString:
+		return ""
+	case TypeNumber, TypeInteger:
+		return float64(0)
+	case TypeBoolean:
+		return false
+	case TypeArray:
+		return []any{}
+	case TypeObject:
+		return map[string]any{}
+	default:
+		return nil
+	}
+}
+
+// check returns an error if the value is not of the type.
+func (t VariableType) check(value any) error {
+	var ok bool
+	switch t {
+	case "", TypeAny:
+		return nil
+	case TypeString:
+		_, ok = value.(string)
+	case TypeNumber:
+		_, ok = toFloat(value)
+	case TypeInteger:
+		f, isNumber := toFloat(value)
+		ok = isNumber && f == math.Trunc(f)
+	case TypeBoolean:
+		_, ok = value.(bool)
+	case TypeArray:
+		_, ok = value.([]any)
+	case TypeObject:
+		_, ok = value.(map[string]any)
+	default:
+		return fmt.Errorf("unknown type %q", t)
+	}
+
+	if !ok {
+		return fmt.Errorf("expected %s but got %s", t, jsonTypeName(value))
+	}
+
+	return nil
+}
+
+// valid returns whether the type is known.
+func (t VariableType) valid() bool {
+	switch t {
+	case "", TypeAny, TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeArray, TypeObject:
+		return true
+	default:
+		return false
+	}
+}
+
+// toFloat returns a number produced by JSON decoding or jq as a float64.
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case *big.Int:
+		// jq produces integers too large for an int as big integers
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	default:
+		return 0, false
+	}
+}
+
+// jsonTypeName returns the name of the JSON type of a value.
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64, int, *big.Int:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// normalizeJSON converts a value decoded from YAML to the types
+// it would have if it was decoded from JSON.
+func normalizeJSON(value any) (any, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	err = json.Unmarshal(content, &normalized)
+	return normalized, err
+}
+
+// resetMistyped sets variables that hold a value not matching their declared
+// type to their initial value, such as after their type changed.
+func (m *Manager) resetMistyped(c *Config) error {
+	return m.Variables.Update(func(tx *VariableTx) error {
+		for key, v := range c.variables() {
+			current, _ := tx.Get(key)
+			if v.Type.check(current) != nil {
+				log.Warn().
+					Str("variable", key).
+					Msg("value does not match the declared type, resetting to initial value")
+				tx.Set(key, v.Value)
+			}
+		}
+
+		return nil
+	})
+}
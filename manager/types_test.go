@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestVariableTypeCheck(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	tests := []struct {
+		typ   VariableType
+		value any
+		ok    bool
+	}{
+		{"", nil, true},
+		{TypeAny, map[string]any{}, true},
+		{TypeString, "on", true},
+		{TypeString, float64(1), false},
+		{TypeString, nil, false},
+		{TypeNumber, float64(1.5), true},
+		{TypeNumber, 1, true},
+		{TypeNumber, huge, true},
+		{TypeNumber, "1", false},
+		{TypeInteger, float64(2), true},
+		{TypeInteger, huge, true},
+		{TypeInteger, float64(2.5), false},
+		{TypeBoolean, false, true},
+		{TypeBoolean, "true", false},
+		{TypeArray, []any{}, true},
+		{TypeArray, map[string]any{}, false},
+		{TypeObject, map[string]any{}, true},
+		{TypeObject, []any{}, false},
+		{"date", "2022-01-01", false},
+	}
+
+	for _, tt := range tests {
+		err := tt.typ.check(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q.check(%#v) = %v, want ok %v", tt.typ, tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestVariableTypeZeroValue(t *testing.T) {
+	for _, typ := range []VariableType{TypeAny, TypeString, TypeNumber, TypeInteger, TypeBoolean, TypeArray, TypeObject} {
+		if err := typ.check(typ.zeroValue()); err != nil {
+			t.Errorf("zero value of %s does not match its type: %v", typ, err)
+		}
+	}
+}
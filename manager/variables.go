@@ -0,0 +1,206 @@
+package manager
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// VariableStore holds the values of the manager's variables,
+// and is safe for concurrent use.
+type VariableStore struct {
+	// maps variable name to value
+	values map[string]any
+	// stores persisted variables, nil if none persist
+	backend VariableBackend
+	// names of the variables that persist
+	persisted map[string]bool
+	// values, backend and persisted mutex
+	mu *sync.RWMutex
+}
+
+// NewVariableStore creates a store with the initial values of variables.
+func NewVariableStore(initial map[string]any) *VariableStore {
+	s := &VariableStore{
+		values: make(map[string]any, len(initial)),
+		mu:     &sync.RWMutex{},
+	}
+
+	for name, value := range initial {
+		s.values[name] = value
+	}
+
+	return s
+}
+
+// Get returns the value of a variable, and whether it exists.
+func (s *VariableStore) Get(name string) (value any, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok = s.values[name]
+	return value, ok
+}
+
+// Snapshot returns a copy of the values of all variables at one point in time.
+func (s *VariableStore) Snapshot() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return copyValues(s.values)
+}
+
+// Update calls fn with a transaction over all variables, and applies the
+// changes made in it if fn returns nil. Other updates wait until fn returns,
+// so fn sees a consistent view of the variables, and should not wait on I/O.
+func (s *VariableStore) Update(fn func(tx *VariableTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &VariableTx{values: copyValues(s.values)}
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	// write through, so changes are only applied once they are stored
+	err = s.save(tx.values)
+	if err != nil {
+		return fmt.Errorf("saving variables: %w", err)
+	}
+
+	s.values = tx.values
+	return nil
+}
+
+// Persist sets which variables keep their values when the manager restarts,
+// and where they are stored. Variables are set to their stored values, if any.
+func (s *VariableStore) Persist(backend VariableBackend, names map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(names) == 0 {
+		s.backend = backend
+		s.persisted = names
+		return nil
+	}
+
+	stored, err := backend.Load()
+	if err != nil {
+		return fmt.Errorf("loading variables: %w", err)
+	}
+
+	s.backend = backend
+	s.persisted = names
+
+	for name, value := range stored {
+		if _, exists := s.values[name]; exists && names[name] {
+			s.values[name] = value
+		}
+	}
+
+	return nil
+}
+
+// save stores the persisted variables if any of them differ from the current values.
+// The store must be locked.
+func (s *VariableStore) save(values map[string]any) error {
+	var changed bool
+	persisted := make(map[string]any)
+	for name := range s.persisted {
+		value, exists := values[name]
+		if !exists {
+			continue
+		}
+
+		persisted[name] = value
+		if current, ok := s.values[name]; !ok || !reflect.DeepEqual(current, value) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return s.backend.Save(persisted)
+}
+
+// Declare sets the variables that exist. Variables that already existed keep
+// their values, new variables get their initial values, and other variables
+// are removed.
+func (s *VariableStore) Declare(initial map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make(map[string]any, len(initial))
+	for name, value := range initial {
+		if current, ok := s.values[name]; ok {
+			value = current
+		}
+
+		values[name] = value
+	}
+
+	s.values = values
+}
+
+// storeState is the state of a VariableStore, to undo changes to it.
+type storeState struct {
+	values    map[string]any
+	backend   VariableBackend
+	persisted map[string]bool
+}
+
+// state returns the current state of the store.
+func (s *VariableStore) state() storeState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return storeState{
+		values:    copyValues(s.values),
+		backend:   s.backend,
+		persisted: s.persisted,
+	}
+}
+
+// restore returns the store to a previous state.
+func (s *VariableStore) restore(state storeState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values = state.values
+	s.backend = state.backend
+	s.persisted = state.persisted
+}
+
+// VariableTx is a view of all variables during VariableStore.Update.
+type VariableTx struct {
+	// maps variable name to value, applied to the store if the update succeeds
+	values map[string]any
+}
+
+// Get returns the value of a variable, and whether it exists.
+func (tx *VariableTx) Get(name string) (value any, ok bool) {
+	value, ok = tx.values[name]
+	return value, ok
+}
+
+// Set sets the value of a variable.
+func (tx *VariableTx) Set(name string, value any) {
+	tx.values[name] = value
+}
+
+// Snapshot returns a copy of the values of all variables in the transaction.
+func (tx *VariableTx) Snapshot() map[string]any {
+	return copyValues(tx.values)
+}
+
+func copyValues(values map[string]any) map[string]any {
+	c := make(map[string]any, len(values))
+	for name, value := range values {
+		c[name] = value
+	}
+
+	return c
+}
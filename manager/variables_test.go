@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// memoryBackend is a VariableBackend that stores values in memory.
+type memoryBackend struct {
+	values map[string]any
+	// number of times Save was called
+	saves int
+	// returned by Save, if set
+	saveErr error
+}
+
+func (b *memoryBackend) Load() (map[string]any, error) {
+	return copyValues(b.values), nil
+}
+
+func (b *memoryBackend) Save(values map[string]any) error {
+	if b.saveErr != nil {
+		return b.saveErr
+	}
+
+	b.saves++
+	b.values = copyValues(values)
+	return nil
+}
+
+func TestVariableStoreUpdate(t *testing.T) {
+	errUpdate := errors.New("update failed")
+
+	tests := []struct {
+		name    string
+		backend *memoryBackend
+		update  func(tx *VariableTx) error
+		wantErr bool
+		want    map[string]any
+	}{
+		{
+			name: "committed",
+			update: func(tx *VariableTx) error {
+				tx.Set("a", float64(2))
+				tx.Set("b", "new")
+				return nil
+			},
+			want: map[string]any{"a": float64(2), "b": "new"},
+		},
+		{
+			name: "rolled back",
+			update: func(tx *VariableTx) error {
+				tx.Set("a", float64(2))
+				return errUpdate
+			},
+			wantErr: true,
+			want:    map[string]any{"a": float64(1)},
+		},
+		{
+			name:    "rolled back when saving fails",
+			backend: &memoryBackend{saveErr: errors.New("disk full")},
+			update: func(tx *VariableTx) error {
+				tx.Set("a", float64(2))
+				return nil
+			},
+			wantErr: true,
+			want:    map[string]any{"a": float64(1)},
+		},
+		{
+			name: "sees its own changes",
+			update: func(tx *VariableTx) error {
+				tx.Set("a", float64(2))
+				value, _ := tx.Get("a")
+				tx.Set("b", value)
+				return nil
+			},
+			want: map[string]any{"a": float64(2), "b": float64(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewVariableStore(map[string]any{"a": float64(1)})
+			if tt.backend != nil {
+				err := s.Persist(tt.backend, map[string]bool{"a": true})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := s.Update(tt.update)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Update() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := s.Snapshot(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("variables = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariableStorePersist(t *testing.T) {
+	backend := &memoryBackend{values: map[string]any{"kept": "stored", "other": "stored"}}
+	s := NewVariableStore(map[string]any{"kept": "initial", "other": "initial"})
+
+	err := s.Persist(backend, map[string]bool{"kept": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"kept": "stored", "other": "initial"}
+	if got := s.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("variables = %v, want %v", got, want)
+	}
+
+	// only changes to persisted variables are saved
+	err = s.Update(func(tx *VariableTx) error {
+		tx.Set("other", "changed")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if backend.saves != 0 {
+		t.Errorf("saved %d times for a variable that does not persist", backend.saves)
+	}
+
+	err = s.Update(func(tx *VariableTx) error {
+		tx.Set("kept", "changed")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStored := map[string]any{"kept": "changed"}
+	if backend.saves != 1 || !reflect.DeepEqual(backend.values, wantStored) {
+		t.Errorf("stored %v after %d saves, want %v after 1", backend.values, backend.saves, wantStored)
+	}
+}
+
+func TestVariableStoreDeclare(t *testing.T) {
+	s := NewVariableStore(map[string]any{"kept": "initial", "removed": "initial"})
+	err := s.Update(func(tx *VariableTx) error {
+		tx.Set("kept", "changed")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Declare(map[string]any{"kept": "new initial", "added": "initial"})
+
+	want := map[string]any{"kept": "changed", "added": "initial"}
+	if got := s.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("variables = %v, want %v", got, want)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // An EventType is associated with a EventPayload,
@@ -25,11 +27,18 @@ const (
 	// A data event is sent by the node when an emitter
 	// emits a data event locally.
 	Data EventType = "data"
+	// An 'expired' event is sent by the node when a
+	// request expired before the action could be performed,
+	// if the node is configured to do so.
+	Expired EventType = "expired"
 )
 
 const (
 	// websub Content-Type used for event payloads.
 	PayloadContentType string = "application/vnd.wts-event-payload.v1+json"
+	// websub Content-Type used for batches of event payloads,
+	// which are a JSON array of payloads.
+	BatchContentType string = "application/vnd.wts-event-batch.v1+json"
 )
 
 var (
@@ -38,11 +47,33 @@ var (
 
 // EventPayload is the message that is sent over websub.
 type EventPayload[MsgType any] struct {
+	// Unique ID of the event, used to drop duplicate deliveries.
+	ID string `json:"id"`
 	// The event data.
 	Data      MsgType   `json:"data"`
 	DateSent  time.Time `json:"dateSent"`
 	EventType EventType `json:"eventType"`
 	Sender    string    `json:"sender"`
+	// Random ID of the sender, which changes every time the sender starts.
+	BootID string `json:"bootID,omitempty"`
+	// Position of the event in the events sent by an emitter since the
+	// sender started, starting at 1. Zero for events not sent by an emitter.
+	Sequence uint64 `json:"sequence,omitempty"`
+	// Whether the event is the retained last value of an emitter,
+	// received after subscribing instead of when it was sent.
+	Retained bool `json:"retained,omitempty"`
+	// When the event expires, nil if it never does.
+	// Expired events are dropped by the receiving node.
+	Expires *time.Time `json:"expires,omitempty"`
+	// W3C traceparent of the event, linking it to the event that caused it.
+	TraceParent string `json:"traceparent,omitempty"`
+	// traceparent of the span of the node handling the event, empty if it is not being handled
+	handleSpan string
+}
+
+// Expired returns whether the event has expired.
+func (e *EventPayload[MsgType]) Expired() bool {
+	return e.Expires != nil && time.Now().After(*e.Expires)
 }
 
 // CopyToAny creates a new copy of e with the [any] type parameter
@@ -51,13 +82,24 @@ func (e *EventPayload[MsgType]) CopyToAny() *EventPayload[any] {
 		return nil
 	}
 
-	var new EventPayload[any]
-	new.Data = e.Data
-	new.DateSent = e.DateSent
-	new.EventType = e.EventType
-	new.Sender = e.Sender
+	return withData[MsgType, any](e, e.Data)
+}
 
-	return &new
+// withData creates a copy of e with different data.
+func withData[From, To any](e *EventPayload[From], data To) *EventPayload[To] {
+	return &EventPayload[To]{
+		ID:          e.ID,
+		Data:        data,
+		DateSent:    e.DateSent,
+		EventType:   e.EventType,
+		Sender:      e.Sender,
+		BootID:      e.BootID,
+		Sequence:    e.Sequence,
+		Retained:    e.Retained,
+		Expires:     e.Expires,
+		TraceParent: e.TraceParent,
+		handleSpan:  e.handleSpan,
+	}
 }
 
 // EncodeMessage encodes a message to JSON
@@ -66,7 +108,8 @@ func EncodeMessage[MsgType any](
 	eventType EventType,
 	sender string,
 ) ([]byte, error) {
-	return json.Marshal(EventPayload[MsgType]{
+	return EncodePayload(&EventPayload[MsgType]{
+		ID:        uuid.NewString(),
 		Data:      msg,
 		DateSent:  time.Now(),
 		EventType: eventType,
@@ -74,6 +117,11 @@ func EncodeMessage[MsgType any](
 	})
 }
 
+// EncodePayload encodes a payload to JSON
+func EncodePayload[MsgType any](payload *EventPayload[MsgType]) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
 // DecodeMessage decodes a message from JSON.
 func DecodeMessage[MsgType any](bytes []byte) (*EventPayload[MsgType], error) {
 	m := &EventPayload[MsgType]{}
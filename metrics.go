@@ -0,0 +1,246 @@
+package wts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the buckets of duration histograms, in seconds.
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Reasons events are dropped by a node.
+const (
+	dropContentType  = "content_type"
+	dropInvalidTopic = "invalid_topic"
+	dropDuplicate    = "duplicate"
+	dropDecode       = "decode"
+	dropExpired      = "expired"
+	dropUnhandled    = "unhandled"
+)
+
+// metricInfo describes a metric in the exposition format.
+type metricInfo struct {
+	help string
+	kind string
+}
+
+// metrics kept by nodes, by name
+var metricInfos = map[string]metricInfo{
+	"wts_events_published_total": {"Events published by the node.", "counter"},
+	"wts_publish_errors_total":   {"Events no hub accepted from the node.", "counter"},
+	"wts_events_received_total":  {"Events received by the node.", "counter"},
+	"wts_events_dropped_total":   {"Events received and dropped by the node.", "counter"},
+	"wts_decode_errors_total":    {"Events that could not be decoded.", "counter"},
+	"wts_actor_executions_total": {"Requests handled by actors on the node.", "counter"},
+	"wts_actor_duration_seconds": {"Time taken by actors to perform actions.", "histogram"},
+}
+
+// metricKey identifies a metric with a set of labels.
+type metricKey struct {
+	name string
+	// labels formatted for the exposition format
+	labels string
+}
+
+// histogram counts observations in buckets.
+type histogram struct {
+	// counts per bucket, not cumulative
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// nodeMetrics are the counters and histograms kept by a node.
+type nodeMetrics struct {
+	counters   map[metricKey]uint64
+	histograms map[metricKey]*histogram
+	// counters and histograms mutex
+	mu *sync.Mutex
+}
+
+func newNodeMetrics() *nodeMetrics {
+	return &nodeMetrics{
+		counters:   make(map[metricKey]uint64),
+		histograms: make(map[metricKey]*histogram),
+		mu:         &sync.Mutex{},
+	}
+}
+
+// WithMetricsPath serves the node's metrics in the Prometheus text format on
+// the path, such as "/_metrics". Paths should not conflict with entity names.
+func WithMetricsPath(path string) NodeOption {
+	return func(n *Node) {
+		n.mux.Handle(path, http.HandlerFunc(n.handleMetrics))
+	}
+}
+
+// handleMetrics serves the node's metrics.
+func (n *Node) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	n.metrics.write(w)
+}
+
+// formatLabels formats label names and values for the exposition format.
+func formatLabels(nameValues ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(nameValues); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(nameValues[i+1])
+		fmt.Fprintf(&b, "%s=\"%s\"", nameValues[i], value)
+	}
+
+	return b.String()
+}
+
+// eventLabels returns the labels of an event URL.
+func eventLabels(eventURL string, extra ...string) string {
+	entityURL, eventType, err := ParseEventURL(eventURL)
+	if err != nil {
+		entityURL = eventURL
+	}
+
+	return formatLabels(append([]string{"entity", entityURL, "event", string(eventType)}, extra...)...)
+}
+
+// add adds to a counter.
+func (m *nodeMetrics) add(name, labels string, delta uint64) {
+	m.mu.Lock()
+	m.counters[metricKey{name, labels}] += delta
+	m.mu.Unlock()
+}
+
+// observe adds an observation to a histogram.
+func (m *nodeMetrics) observe(name, labels string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricKey{name, labels}
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(durationBuckets))}
+		m.histograms[key] = h
+	}
+
+	for i, bound := range durationBuckets {
+		if value <= bound {
+			h.buckets[i]++
+			break
+		}
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// published records that events were published to a topic.
+func (m *nodeMetrics) published(topic, contentType string, content []byte, err error) {
+	events := uint64(1)
+	if contentType == BatchContentType {
+		var batch []json.RawMessage
+		if json.Unmarshal(content, &batch) == nil {
+			events = uint64(len(batch))
+		}
+	}
+
+	if err != nil {
+		m.add("wts_publish_errors_total", eventLabels(topic), events)
+		return
+	}
+
+	m.add("wts_events_published_total", eventLabels(topic), events)
+}
+
+// received records that an event was received on a topic.
+func (m *nodeMetrics) received(topic string) {
+	m.add("wts_events_received_total", eventLabels(topic), 1)
+}
+
+// dropped records that an event received on a topic was dropped.
+func (m *nodeMetrics) dropped(topic, reason string) {
+	m.add("wts_events_dropped_total", eventLabels(topic, "reason", reason), 1)
+}
+
+// decodeFailed records that an event received on a topic could not be decoded.
+func (m *nodeMetrics) decodeFailed(topic string) {
+	m.add("wts_decode_errors_total", eventLabels(topic), 1)
+}
+
+// actorHandled records how an actor handled a request, and how long it took.
+func (m *nodeMetrics) actorHandled(entityURL, result string, took time.Duration) {
+	labels := formatLabels("entity", entityURL)
+	m.add("wts_actor_executions_total", formatLabels("entity", entityURL, "result", result), 1)
+
+	if result != "skipped" {
+		m.observe("wts_actor_duration_seconds", labels, took.Seconds())
+	}
+}
+
+// write writes the metrics in the Prometheus text format.
+func (m *nodeMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(metricInfos))
+	for name := range metricInfos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		info := metricInfos[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, info.help, name, info.kind)
+
+		if info.kind == "histogram" {
+			m.writeHistograms(w, name)
+		} else {
+			m.writeCounters(w, name)
+		}
+	}
+}
+
+func (m *nodeMetrics) writeCounters(w io.Writer, name string) {
+	var keys []metricKey
+	for key := range m.counters {
+		if key.name == name {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].labels < keys[j].labels })
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, key.labels, m.counters[key])
+	}
+}
+
+func (m *nodeMetrics) writeHistograms(w io.Writer, name string) {
+	var keys []metricKey
+	for key := range m.histograms {
+		if key.name == name {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].labels < keys[j].labels })
+
+	for _, key := range keys {
+		h := m.histograms[key]
+
+		var cumulative uint64
+		for i, bound := range durationBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, key.labels, bound, cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, key.labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", name, key.labels, h.sum)
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, key.labels, h.count)
+	}
+}
@@ -1,7 +1,9 @@
 package wts
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,7 +12,9 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/notnotquinn/go-websub"
 )
 
@@ -32,14 +36,48 @@ type Node struct {
 	emitters map[string]*emitterProxy
 	// emitters mutex
 	emittersMu *sync.RWMutex
-	// maps entity URL to event type to hook
-	hooks map[string]map[EventType]*eventHook
+	// maps entity URL to event type to hooks
+	hooks map[string]map[EventType][]*eventHook
 	// hooks mutex
 	hooksMu *sync.RWMutex
+	// hooks added for every topic matching a pattern
+	patternHooks []topicMatcher
+	// patternHooks mutex
+	patternHooksMu *sync.RWMutex
 	// subscriptions required for this node to function
-	subscriptions []*websub.SubscriberSubscription
-	// subscriptions mutex
+	subscriptions map[subscriptionKey]*nodeSubscription
+	// maps subscription ID to the lease reported by the hub
+	leases map[string]*subscriptionLease
+	// subscriptions and leases mutex
 	subscriptionsMu *sync.RWMutex
+	// called when a subscription is lost unexpectedly
+	onUnsubscribed OnUnsubscribedFunc
+	// interval between checks of subscription leases
+	leaseCheckInterval time.Duration
+	// topics retained values were fetched for since subscribing
+	retainedFetched map[string]bool
+	// requests a check of subscription leases
+	leaseChecks chan struct{}
+	// closed to stop maintaining subscriptions
+	stopMaintenance chan struct{}
+	// hubs the node publishes and subscribes through, the first is the primary hub
+	hubs []*nodeHub
+	// hubs mutex
+	hubsMu *sync.RWMutex
+	// interval a hub is skipped for after it fails to answer
+	hubRetryInterval time.Duration
+	// recently received events, used to drop duplicate deliveries
+	seen *seenEvents
+	// random ID that changes every time the node is created
+	bootID string
+	// whether expired requests for actors are published as expired events
+	expiredEvents bool
+	// counters and histograms about the node's events
+	metrics *nodeMetrics
+	// called for every span recorded, nil if there is none
+	spanExporter SpanExporter
+	// Used in initialization of hubs only
+	hubURLs []string
 	// Used in initialization of publisher only
 	pubOptions []websub.PublisherOption
 	// Used in initialization of subscriber only
@@ -61,11 +99,16 @@ func NewNode(baseURL, hubURL string, options ...NodeOption) *Node {
 		baseURL:         baseURL,
 		actors:          make(map[string]*actorProxy),
 		emitters:        make(map[string]*emitterProxy),
-		hooks:           make(map[string]map[EventType]*eventHook),
+		hooks:           make(map[string]map[EventType][]*eventHook),
 		hooksMu:         &sync.RWMutex{},
+		patternHooksMu:  &sync.RWMutex{},
 		emittersMu:      &sync.RWMutex{},
 		actorsMu:        &sync.RWMutex{},
+		subscriptions:   make(map[subscriptionKey]*nodeSubscription),
+		leases:          make(map[string]*subscriptionLease),
+		retainedFetched: make(map[string]bool),
 		subscriptionsMu: &sync.RWMutex{},
+		leaseChecks:     make(chan struct{}, 1),
 		pubOptions: []websub.PublisherOption{
 			// Used to allow subscribers to subscribe to topic
 			// urls we dont publish but are still point to our server
@@ -74,19 +117,45 @@ func NewNode(baseURL, hubURL string, options ...NodeOption) *Node {
 			// urls that arent on our server
 			websub.PublisherWithPostBodyAsContent(true),
 		},
-		subOptions: []websub.SubscriberOption{},
-		mux:        http.NewServeMux(),
+		subOptions:         []websub.SubscriberOption{},
+		mux:                http.NewServeMux(),
+		leaseCheckInterval: defaultLeaseCheckInterval,
+		hubsMu:             &sync.RWMutex{},
+		hubRetryInterval:   defaultHubRetryInterval,
+		seen:               newSeenEvents(),
+		bootID:             uuid.NewString(),
+		metrics:            newNodeMetrics(),
+		hubURLs:            []string{hubURL},
 	}
 
 	for _, opt := range options {
 		opt(n)
 	}
 
+	if n.seen.path != "" {
+		err := n.seen.load()
+		if err != nil {
+			log.Err(err).
+				Str("path", n.seen.path).
+				Msg("could not load seen events")
+		}
+	}
+
 	n.Publisher = websub.NewPublisher(baseURL+"/", hubURL, n.pubOptions...)
 	n.Subscriber = websub.NewSubscriber(baseURL+"/_s/", n.subOptions...)
+	n.hubs = append(n.hubs, &nodeHub{url: hubURL, publisher: n.Publisher})
+	for _, extraHubURL := range n.hubURLs[1:] {
+		n.hubs = append(n.hubs, &nodeHub{
+			url: extraHubURL,
+			// an empty base URL keeps the publisher from storing content,
+			// as only the primary publisher serves content
+			publisher: websub.NewPublisher("", extraHubURL, n.pubOptions...),
+		})
+	}
 	// unallocate
 	n.pubOptions = nil
 	n.subOptions = nil
+	n.hubURLs = nil
 
 	// "/:actor.ActorName()/request"
 	//    - action request
@@ -103,9 +172,12 @@ func NewNode(baseURL, hubURL string, options ...NodeOption) *Node {
 	//    - received by anyone
 	// these events are ones associated with this node,
 	// not necessarily ones published by this node.
-	n.mux.Handle("/", n.Publisher)
+	// retained values are served before the publisher's content
+	n.mux.Handle("/", http.HandlerFunc(n.handleTopic))
 	// "/_s/*" is for websub subscription callbacks
-	n.mux.Handle("/_s/", http.StripPrefix("/_s", n.Subscriber))
+	n.mux.Handle("/_s/", http.StripPrefix("/_s", http.HandlerFunc(n.handleCallback)))
+	// "/_d/*" advertises topics on a specific hub, for subscribing through each hub
+	n.mux.Handle("/_d/", http.StripPrefix("/_d", http.HandlerFunc(n.handleDiscovery)))
 
 	return n
 }
@@ -116,6 +188,14 @@ func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 type NodeOption func(n *Node)
 
+// WithExpiredEvents sets whether the node publishes an expired event
+// when a request for one of its actors expires before it is performed.
+func WithExpiredEvents(enabled bool) NodeOption {
+	return func(n *Node) {
+		n.expiredEvents = enabled
+	}
+}
+
 // WithPublisherOptions defaults to
 //
 //  []websub.PublisherOption{
@@ -153,6 +233,8 @@ func (n *Node) SubscribeAll() error {
 	}
 
 	n.subscribed = true
+	n.stopMaintenance = make(chan struct{})
+	go n.maintainSubscriptions(n.stopMaintenance)
 
 	n.actorsMu.RLock()
 	for actorURL := range n.actors {
@@ -176,7 +258,7 @@ func (n *Node) SubscribeAll() error {
 	}
 	n.hooksMu.RUnlock()
 
-	return nil
+	return n.discoverTopics()
 }
 
 // UnsubscribeAll removes all required subscriptions for the node
@@ -185,41 +267,144 @@ func (n *Node) UnsubscribeAll() error {
 		return errors.New("not subscribed")
 	}
 
+	close(n.stopMaintenance)
+
+	n.subscriptionsMu.Lock()
 	n.subscribed = false
+	subscriptions := n.subscriptions
+	n.subscriptions = make(map[subscriptionKey]*nodeSubscription)
+	n.leases = make(map[string]*subscriptionLease)
+	n.retainedFetched = make(map[string]bool)
+	n.subscriptionsMu.Unlock()
 
-	n.subscriptionsMu.RLock()
-	for _, subscription := range n.subscriptions {
-		err := n.Unsubscribe(subscription)
+	for _, ns := range subscriptions {
+		if ns.sub == nil {
+			continue
+		}
+
+		err := n.Unsubscribe(ns.sub)
 		if err != nil {
-			n.subscriptionsMu.RUnlock()
 			return err
 		}
 	}
 
-	n.subscriptionsMu.RUnlock()
 	return nil
 }
 
-// subscribeTopic subscribes to a topic with a random secret
-// and the node's callback function
+// subscribeTopic subscribes to a topic through each hub,
+// unless the node is already subscribed to it.
+//
+// An error is only returned if no subscription could be made, failed
+// subscriptions are retried while maintaining subscriptions.
 func (n *Node) subscribeTopic(topic string) error {
+	var subscribed bool
+	var lastErr error
+
+	for _, hub := range n.subscriptionHubs() {
+		err := n.subscribeTopicThrough(topic, hub)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		subscribed = true
+	}
+
+	if !subscribed {
+		return lastErr
+	}
+
+	return nil
+}
+
+// subscribeTopicThrough subscribes to a topic through a hub, unless
+// the node is already subscribed to it. If hub is empty, the hub
+// advertised by the topic is used.
+//
+// Failed subscriptions are still recorded, to be retried
+// while maintaining subscriptions.
+func (n *Node) subscribeTopicThrough(topic, hub string) error {
+	key := subscriptionKey{topic: topic, hub: hub}
+
+	n.subscriptionsMu.RLock()
+	_, exists := n.subscriptions[key]
+	n.subscriptionsMu.RUnlock()
+
+	if exists {
+		return nil
+	}
+
+	subscription, err := n.newSubscription(topic, hub)
+	if err != nil {
+		log.Err(err).
+			Str("topic", topic).
+			Str("hub", hub).
+			Msg("could not subscribe to topic")
+	}
+
+	n.subscriptionsMu.Lock()
+	n.subscriptions[key] = &nodeSubscription{
+		topic:     topic,
+		hub:       hub,
+		sub:       subscription,
+		requested: time.Now(),
+	}
+	n.subscriptionsMu.Unlock()
+
+	return err
+}
+
+// unsubscribeTopic removes the node's subscriptions to a topic through every hub.
+func (n *Node) unsubscribeTopic(topic string) error {
+	var subs []*websub.SubscriberSubscription
+
+	n.subscriptionsMu.Lock()
+	for key, ns := range n.subscriptions {
+		if key.topic != topic {
+			continue
+		}
+
+		delete(n.subscriptions, key)
+		if ns.sub != nil {
+			delete(n.leases, ns.sub.ID)
+			subs = append(subs, ns.sub)
+		}
+	}
+	delete(n.retainedFetched, topic)
+	n.subscriptionsMu.Unlock()
+
+	var lastErr error
+	for _, sub := range subs {
+		err := n.Unsubscribe(sub)
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// newSubscription subscribes to a topic through a hub with a random
+// secret and the node's callback function.
+//
+// If hub is empty, the hub advertised by the topic is used.
+func (n *Node) newSubscription(topic, hub string) (*websub.SubscriberSubscription, error) {
 	secret := make([]byte, 100)
 	_, err := rand.Read(secret)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	topicURL := topic
+	if hub != "" {
+		topicURL = n.discoveryURL(topic, hub)
 	}
 
-	subscription, err := n.Subscriber.Subscribe(
-		topic,
+	return n.Subscriber.Subscribe(
+		topicURL,
 		base64.RawURLEncoding.EncodeToString(secret),
 		n.handleSubscription,
 	)
-
-	n.subscriptionsMu.Lock()
-	n.subscriptions = append(n.subscriptions, subscription)
-	n.subscriptionsMu.Unlock()
-
-	return err
 }
 
 // handleSubscription receives all events from all subscriptions the node makes.
@@ -228,38 +413,49 @@ func (n *Node) handleSubscription(
 	contentType string,
 	body io.Reader,
 ) {
+	if n.isTopicsURL(sub.Topic) {
+		n.handleTopicUpdates(body)
+		return
+	}
+
+	n.handleEvent(sub.Topic, contentType, body)
+}
+
+// handleEvent handles an event received on a topic.
+func (n *Node) handleEvent(topic, contentType string, body io.Reader) {
+	if contentType == BatchContentType {
+		n.handleBatch(topic, body)
+		return
+	}
+
+	n.metrics.received(topic)
+
 	if contentType != PayloadContentType {
+		n.metrics.dropped(topic, dropContentType)
 		log.Debug().
 			Str("content-type", contentType).
 			Msg("incorrect payload content-type received from subscription")
 		return // ignore
 	}
 
-	// if !strings.HasPrefix(sub.Topic, n.baseURL) {
+	// if !strings.HasPrefix(topic, n.baseURL) {
 	// 	log.Debug().
-	// 		Str("topic", sub.Topic).
+	// 		Str("topic", topic).
 	// 		Str("baseURL", n.baseURL).
 	// 		Msg("entity URL in subscription does not start with node baseURL")
 	// 	return // ignore
 	// }
 
-	entityURL, eventType, err := ParseEventURL(sub.Topic)
+	entityURL, eventType, err := ParseEventURL(topic)
 	if err != nil {
+		n.metrics.dropped(topic, dropInvalidTopic)
 		log.Debug().
 			AnErr("parsingError", err).
-			Str("topic", sub.Topic).
+			Str("topic", topic).
 			Msg("invalid entity url as subscribed topic")
 		return
 	}
 
-	encoder, err := n.getEventEncoder(sub.Topic)
-	if err != nil {
-		log.Err(err).
-			Str("topic", sub.Topic).
-			Msg("could not get encoder for subscribed topic")
-		return
-	}
-
 	content, err := io.ReadAll(body)
 	if err != nil {
 		log.Err(err).
@@ -267,76 +463,142 @@ func (n *Node) handleSubscription(
 		return
 	}
 
-	message, err := encoder.Decode(content)
+	envelope, err := DecodeMessage[json.RawMessage](content)
 	if err != nil {
+		n.metrics.decodeFailed(topic)
+		n.metrics.dropped(topic, dropDecode)
 		log.Err(err).
 			Msg("could not decode subscription content")
 		return
 	}
 
-	// is there a hook?
-	n.hooksMu.RLock()
-	entityHooks, exists := n.hooks[entityURL]
-	n.hooksMu.RUnlock()
-	if exists {
-		n.hooksMu.RLock()
-		hook, exists := entityHooks[eventType]
-		if exists {
-			err := hook.happened(sub.Topic, message)
-			if err != nil {
-				log.Err(err).Msg("event hook reported an error")
-			}
+	// hubs may deliver the same event more than once, and the same event is
+	// delivered once by each hub. Only decoded events count as seen, so a
+	// redelivery of an event that could not be decoded is still handled.
+	if !n.seen.add(eventKey(topic, envelope, content)) {
+		n.metrics.dropped(topic, dropDuplicate)
+		log.Debug().
+			Str("topic", topic).
+			Msg("dropped duplicate event")
+		return // ignore
+	}
+
+	n.actorsMu.RLock()
+	actor, actorExists := n.actors[entityURL]
+	n.actorsMu.RUnlock()
+	actorExists = actorExists && eventType == Request
+
+	if envelope.Expired() {
+		n.metrics.dropped(topic, dropExpired)
+		log.Debug().
+			Str("topic", topic).
+			Time("expires", *envelope.Expires).
+			Msg("dropped expired event")
+
+		if actorExists && n.expiredEvents {
+			handleSpan, end := n.startHandleSpan(topic, envelope)
+			n.broadcastExpired(entityURL, actor, content, handleSpan)
+			end()
 		}
-		n.hooksMu.RUnlock()
-		return
+		return // ignore
 	}
 
-	// Call actor things
-	switch eventType {
-	case Request:
-		n.actorsMu.RLock()
-		actor, exists := n.actors[entityURL]
-		n.actorsMu.RUnlock()
-		if !exists {
-			// how would this even happen
-			log.Error().
-				Str("topic", sub.Topic).
-				Msg("actor does not exist")
-			return
+	hooks := n.getEventHooks(entityURL, eventType)
+	if len(hooks) == 0 && !actorExists {
+		n.metrics.dropped(topic, dropUnhandled)
+		log.Debug().
+			Str("topic", topic).
+			Msg("no hooks or actor for event")
+		return // ignore
+	}
+
+	handleSpan, end := n.startHandleSpan(topic, envelope)
+	defer end()
+
+	// Call every hook
+	for _, hook := range hooks {
+		if !hook.accept(topic, envelope) {
+			continue
 		}
 
-		if actor.shouldAct(message) {
-			if actor.act(message) {
-				eventURL := entityURL + "/" + string(Executed)
-				err := n.Broadcast(eventURL, message.Data)
-				if err != nil {
-					log.Err(err).
-						Str("eventURL", eventURL).
-						Msg("could not broadcast execution")
-					return
-				}
-			}
+		message, err := hook.Decode(content)
+		if err != nil {
+			n.metrics.decodeFailed(topic)
+			log.Err(err).
+				Str("topic", topic).
+				Msg("could not decode subscription content for event hook")
+			continue
+		}
+		message.handleSpan = handleSpan
+
+		err = hook.happened(topic, message)
+		if err != nil {
+			log.Err(err).Msg("event hook reported an error")
 		}
+	}
 
+	if !actorExists {
 		return
+	}
 
-	case Executed, Data:
-		log.Debug().
-			Str("eventType", string(eventType)).
-			Msg("unexpected eventType")
-		return // ignore
+	// Call actor things
+	message, err := actor.Decode(content)
+	if err != nil {
+		n.metrics.decodeFailed(topic)
+		log.Err(err).
+			Str("topic", topic).
+			Msg("could not decode subscription content for actor")
+		return
+	}
+	message.handleSpan = handleSpan
 
-	default:
-		log.Debug().
-			Str("eventType", string(eventType)).
-			Msg("unrecognized eventType")
-		return // ignore
+	if !actor.shouldAct(message) {
+		n.metrics.actorHandled(entityURL, "skipped", 0)
+		return
+	}
+
+	started := time.Now()
+	ok := actor.act(message)
+	if !ok {
+		n.metrics.actorHandled(entityURL, "failed", time.Since(started))
+		return
+	}
+	n.metrics.actorHandled(entityURL, "executed", time.Since(started))
+
+	eventURL := entityURL + "/" + string(Executed)
+	err = n.BroadcastContext(message.Context(), eventURL, message.Data)
+	if err != nil {
+		log.Err(err).
+			Str("eventURL", eventURL).
+			Msg("could not broadcast execution")
+		return
+	}
+}
+
+// broadcastExpired publishes an expired event for a request to a local actor
+// that expired before it could be performed, linked to the span handling it.
+func (n *Node) broadcastExpired(entityURL string, actor *actorProxy, content []byte, handleSpan string) {
+	message, err := actor.Decode(content)
+	if err != nil {
+		log.Err(err).
+			Str("entityURL", entityURL).
+			Msg("could not decode expired request")
+		return
+	}
+	message.handleSpan = handleSpan
+
+	eventURL := entityURL + "/" + string(Expired)
+	err = n.BroadcastContext(message.Context(), eventURL, message.Data)
+	if err != nil {
+		log.Err(err).
+			Str("eventURL", eventURL).
+			Msg("could not broadcast expiry")
 	}
 }
 
 // ParseEventURL parses the event type and entity of an Event URL
 //
-// An event URL is any url that ends with /data /request or /executed
+// An event URL is any url that ends with /data /request /executed or /expired
 func ParseEventURL(eventURL string) (entityURL string, eventType EventType, err error) {
 	parsed, err := url.Parse(eventURL)
 	if err != nil {
@@ -356,7 +618,7 @@ func ParseEventURL(eventURL string) (entityURL string, eventType EventType, err
 	entityURL = parsed.String()
 
 	switch eventType {
-	case Executed, Data, Request:
+	case Executed, Data, Request, Expired:
 		return
 
 	default:
@@ -364,33 +626,64 @@ func ParseEventURL(eventURL string) (entityURL string, eventType EventType, err
 	}
 }
 
+// Broadcast publishes an event with the message as its data.
+//
+// The message must be of the type used by the actor, emitter,
+// or hook for the event on this node.
 func (n *Node) Broadcast(eventURL string, msgData any) error {
-	content, err := n.encode(eventURL, msgData)
+	return n.BroadcastContext(context.Background(), eventURL, msgData)
+}
+
+// BroadcastContext is like Broadcast, but links the event
+// to the event carried by ctx, see ContextWithEvent.
+func (n *Node) BroadcastContext(ctx context.Context, eventURL string, msgData any) error {
+	payload, err := n.newPayload(ctx, eventURL, msgData)
 	if err != nil {
 		return err
 	}
 
-	err = n.Publish(eventURL, PayloadContentType, content)
+	return n.broadcastPayload(eventURL, payload)
+}
+
+// broadcastPayload encodes and publishes a payload according to the event's type.
+func (n *Node) broadcastPayload(eventURL string, payload *EventPayload[any]) error {
+	encoder, err := n.getEventEncoder(eventURL)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return n.publishPayload(eventURL, encoder, payload)
+}
+
+// publishPayload encodes a payload with the encoder and publishes it.
+func (n *Node) publishPayload(eventURL string, encoder *encoderProxy, payload *EventPayload[any]) error {
+	content, err := encoder.Encode(payload)
+	if err != nil {
+		return err
+	}
+
+	return n.publish(eventURL, PayloadContentType, content)
 }
 
 // BroadcastAny does not perform type checks
 func (n *Node) BroadcastAny(eventURL string, msgData any) error {
-	_, eventType, err := ParseEventURL(eventURL)
+	return n.BroadcastAnyContext(context.Background(), eventURL, msgData)
+}
+
+// BroadcastAnyContext is like BroadcastAny, but links the event
+// to the event carried by ctx, see ContextWithEvent.
+func (n *Node) BroadcastAnyContext(ctx context.Context, eventURL string, msgData any) error {
+	payload, err := n.newPayload(ctx, eventURL, msgData)
 	if err != nil {
 		return err
 	}
 
-	content, err := EncodeMessage(msgData, eventType, n.baseURL)
+	content, err := EncodePayload(payload)
 	if err != nil {
 		return err
 	}
 
-	err = n.Publish(eventURL, PayloadContentType, content)
+	err = n.publish(eventURL, PayloadContentType, content)
 	if err != nil {
 		return err
 	}
@@ -398,6 +691,27 @@ func (n *Node) BroadcastAny(eventURL string, msgData any) error {
 	return nil
 }
 
+// newPayload creates a payload sent by this node for the event,
+// linked to the event carried by ctx.
+func (n *Node) newPayload(ctx context.Context, eventURL string, msgData any) (*EventPayload[any], error) {
+	_, eventType, err := ParseEventURL(eventURL)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &EventPayload[any]{
+		ID:        uuid.NewString(),
+		Data:      msgData,
+		DateSent:  time.Now(),
+		EventType: eventType,
+		Sender:    n.baseURL,
+		BootID:    n.bootID,
+	}
+
+	n.tracePayload(ctx, eventURL, payload)
+	return payload, nil
+}
+
 // getEventEncoder gets the encoder proxy for a specific event
 func (n *Node) getEventEncoder(eventURL string) (*encoderProxy, error) {
 	entityURL, eventType, err := ParseEventURL(eventURL)
@@ -408,7 +722,7 @@ func (n *Node) getEventEncoder(eventURL string) (*encoderProxy, error) {
 
 	// check emitter, actor
 	switch eventType {
-	case Request, Executed:
+	case Request, Executed, Expired:
 		n.actorsMu.RLock()
 		actor, exists := n.actors[entityURL]
 		n.actorsMu.RUnlock()
@@ -432,18 +746,9 @@ func (n *Node) getEventEncoder(eventURL string) (*encoderProxy, error) {
 
 	// check hooks
 	if encoder == nil {
-		n.hooksMu.RLock()
-		entityHooks, exists := n.hooks[entityURL]
-		n.hooksMu.RUnlock()
-
-		if exists {
-			n.hooksMu.RLock()
-			hook, exists := entityHooks[eventType]
-			n.hooksMu.RUnlock()
-
-			if exists {
-				encoder = hook.encoderProxy
-			}
+		hooks := n.getEventHooks(entityURL, eventType)
+		if len(hooks) > 0 {
+			encoder = hooks[0].encoderProxy
 		}
 	}
 
@@ -453,23 +758,3 @@ func (n *Node) getEventEncoder(eventURL string) (*encoderProxy, error) {
 
 	return encoder, nil
 }
-
-// Encode encodes a message according to the event's type
-func (n *Node) encode(eventURL string, msgData any) ([]byte, error) {
-	_, eventType, err := ParseEventURL(eventURL)
-	if err != nil {
-		return nil, err
-	}
-
-	encoder, err := n.getEventEncoder(eventURL)
-	if err != nil {
-		return nil, err
-	}
-
-	content, err := encoder.Encode(msgData, eventType, n.baseURL)
-	if err != nil {
-		return nil, err
-	}
-
-	return content, nil
-}
@@ -0,0 +1,299 @@
+package wts
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/notnotquinn/go-websub"
+)
+
+var (
+	// A hub's topic listing could not be fetched, which requires websub.HubExposeTopics.
+	ErrTopicListingUnavailable = errors.New("hub topic listing is not available")
+)
+
+// topicMatcher is a hook that is added for every topic matching a pattern.
+type topicMatcher interface {
+	// topicFound adds a hook for the topic if it matches the pattern.
+	topicFound(topic string)
+}
+
+// PatternHook receives events from every event URL matching a pattern.
+type PatternHook[MsgType any] struct {
+	// node the hook was added to
+	node *Node
+	// the pattern as passed to AddPatternHook
+	pattern string
+	// the pattern compiled to a regular expression
+	re *regexp.Regexp
+	// called for every event
+	onEvent OnEventFunc[MsgType]
+	// options used for every hook
+	options []HookOption
+	// maps matched event URL to the hook added for it
+	hooks map[string]*Hook[MsgType]
+	// hooks mutex
+	mu *sync.Mutex
+}
+
+// AddPatternHook adds a hook for every event URL matching the pattern, such as
+// "http://pi1/sensors/*/data" or "http://node/**/executed".
+//
+// In patterns "*" matches anything but a slash, and "**" matches anything.
+//
+// Matching event URLs are found using the topic listing of the node's hubs,
+// which must be created with websub.HubExposeTopics. Hubs only list topics
+// that have been published or subscribed to. New topics are subscribed to as
+// the hubs announce them.
+func AddPatternHook[MsgType any](
+	node *Node,
+	pattern string,
+	onEvent OnEventFunc[MsgType],
+	options ...HookOption,
+) (*PatternHook[MsgType], error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the options once, instead of for every matching topic
+	if err := newHookOptions(options).err; err != nil {
+		return nil, err
+	}
+
+	ph := &PatternHook[MsgType]{
+		node:    node,
+		pattern: pattern,
+		re:      re,
+		onEvent: onEvent,
+		options: options,
+		hooks:   make(map[string]*Hook[MsgType]),
+		mu:      &sync.Mutex{},
+	}
+
+	node.patternHooksMu.Lock()
+	node.patternHooks = append(node.patternHooks, ph)
+	node.patternHooksMu.Unlock()
+
+	if node.subscribed {
+		err := node.discoverTopics()
+		if err != nil {
+			ph.Remove()
+			return nil, err
+		}
+	}
+
+	return ph, nil
+}
+
+// Pattern returns the pattern the hook matches event URLs with.
+func (ph *PatternHook[MsgType]) Pattern() string {
+	return ph.pattern
+}
+
+// EventURLs returns the event URLs that matched the pattern so far.
+func (ph *PatternHook[MsgType]) EventURLs() []string {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	urls := make([]string, 0, len(ph.hooks))
+	for eventURL := range ph.hooks {
+		urls = append(urls, eventURL)
+	}
+
+	return urls
+}
+
+// FilterStats returns how many events passed the filters of the hooks added for
+// matching event URLs, and how many were filtered out.
+func (ph *PatternHook[MsgType]) FilterStats() FilterStats {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	var stats FilterStats
+	for _, hook := range ph.hooks {
+		s := hook.FilterStats()
+		stats.Passed += s.Passed
+		stats.Filtered += s.Filtered
+		stats.Errors += s.Errors
+	}
+
+	return stats
+}
+
+// Remove removes the hook and every hook added for matching event URLs.
+func (ph *PatternHook[MsgType]) Remove() error {
+	ph.node.patternHooksMu.Lock()
+	for i, m := range ph.node.patternHooks {
+		if m == topicMatcher(ph) {
+			ph.node.patternHooks = append(ph.node.patternHooks[:i:i], ph.node.patternHooks[i+1:]...)
+			break
+		}
+	}
+	ph.node.patternHooksMu.Unlock()
+
+	ph.mu.Lock()
+	hooks := ph.hooks
+	ph.hooks = make(map[string]*Hook[MsgType])
+	ph.mu.Unlock()
+
+	var lastErr error
+	for _, hook := range hooks {
+		err := hook.Remove()
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (ph *PatternHook[MsgType]) topicFound(topic string) {
+	entityURL, eventType, err := ParseEventURL(topic)
+	if err != nil || !ph.re.MatchString(topic) {
+		return
+	}
+
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+
+	if _, exists := ph.hooks[topic]; exists {
+		return
+	}
+
+	hook := newHook[MsgType](ph.node, entityURL, eventType, ph.options)
+	ph.hooks[topic] = hook
+
+	err = hook.add(eventType, ph.onEvent)
+	if err != nil {
+		log.Err(err).
+			Str("pattern", ph.pattern).
+			Str("topic", topic).
+			Msg("could not add hook for matching topic")
+	}
+}
+
+// compilePattern compiles an event URL pattern to a regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '*' {
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			continue
+		}
+
+		if i+1 < len(pattern) && pattern[i+1] == '*' {
+			b.WriteString(".*")
+			i++
+		} else {
+			b.WriteString("[^/]*")
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// topicsURL returns the URL of a hub's topic listing.
+func topicsURL(hubURL string) string {
+	return strings.TrimRight(hubURL, "/") + "/topics"
+}
+
+// isTopicsURL returns whether the topic is the topic listing of one of the node's hubs.
+func (n *Node) isTopicsURL(topic string) bool {
+	for _, hub := range n.hubs {
+		if topicsURL(hub.url) == topic {
+			return true
+		}
+	}
+
+	return false
+}
+
+// discoverTopics gets the topics known to each hub, and subscribes
+// to the hubs' topic listings to be told about new topics.
+//
+// Does nothing if there are no pattern hooks.
+func (n *Node) discoverTopics() error {
+	n.patternHooksMu.RLock()
+	hasPatternHooks := len(n.patternHooks) > 0
+	n.patternHooksMu.RUnlock()
+
+	if !hasPatternHooks {
+		return nil
+	}
+
+	var found bool
+	var lastErr error
+	for _, hub := range n.hubs {
+		err := n.fetchTopics(topicsURL(hub.url))
+		if err == nil {
+			// the topic listing advertises its own hub
+			err = n.subscribeTopicThrough(topicsURL(hub.url), "")
+		}
+
+		if err != nil {
+			log.Err(err).
+				Str("hub", hub.url).
+				Msg("could not discover topics on hub")
+			lastErr = err
+			continue
+		}
+
+		found = true
+	}
+
+	if !found {
+		return lastErr
+	}
+
+	return nil
+}
+
+// fetchTopics gets all topics from a hub's topic listing.
+func (n *Node) fetchTopics(topicsURL string) error {
+	resp, err := http.Get(topicsURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ErrTopicListingUnavailable
+	}
+
+	n.handleTopicUpdates(resp.Body)
+	return nil
+}
+
+// handleTopicUpdates passes all topics in a topic listing to the pattern hooks.
+func (n *Node) handleTopicUpdates(body io.Reader) {
+	var updates websub.HubTopicUpdates
+	err := json.NewDecoder(body).Decode(&updates)
+	if err != nil {
+		log.Err(err).Msg("could not decode topic listing")
+		return
+	}
+
+	topics := updates.AllTopics
+	if updates.NewTopic != "" {
+		topics = append(topics, updates.NewTopic)
+	}
+
+	n.patternHooksMu.RLock()
+	matchers := append([]topicMatcher(nil), n.patternHooks...)
+	n.patternHooksMu.RUnlock()
+
+	for _, topic := range topics {
+		for _, m := range matchers {
+			m.topicFound(topic)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package wts
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 )
 
 type actorProxy struct {
@@ -27,12 +30,7 @@ func proxyIndicatorFunc[MsgType any](
 	return func(msg *EventPayload[any]) (ok bool) {
 		switch msgData := msg.Data.(type) {
 		case MsgType:
-			return cb(&EventPayload[MsgType]{
-				Data:      msgData,
-				DateSent:  msg.DateSent,
-				EventType: msg.EventType,
-				Sender:    msg.Sender,
-			})
+			return cb(withData(msg, msgData))
 		default:
 			panic(panicMessage)
 		}
@@ -43,16 +41,16 @@ func proxyIndicatorFunc[MsgType any](
 type encoderProxy struct {
 	// Decodes a message using the correct type for the proxied type
 	Decode func(bytes []byte) (*EventPayload[any], error)
-	// Encodes a message using the correct type for the proxied type
-	Encode func(msgData any, eventType EventType, sender string) ([]byte, error)
+	// Encodes a payload using the correct type for the proxied type
+	Encode func(payload *EventPayload[any]) ([]byte, error)
 }
 
 func NewEncoderProxy[MsgType any]() *encoderProxy {
 	return &encoderProxy{
-		Encode: func(msg any, eventType EventType, sender string) ([]byte, error) {
-			switch msg := msg.(type) {
+		Encode: func(payload *EventPayload[any]) ([]byte, error) {
+			switch msg := payload.Data.(type) {
 			case MsgType:
-				return EncodeMessage(msg, eventType, sender)
+				return EncodePayload(withData(payload, msg))
 			default:
 				return nil, fmt.Errorf(
 					"encoderProxy.encode: expected type %T but found %T",
@@ -73,22 +71,47 @@ func NewEncoderProxy[MsgType any]() *encoderProxy {
 
 type emitterProxy struct {
 	*encoderProxy
-	// dataChannel returns a channel that gets all
-	// messages from the proxied emitter
-	dataChannel func() <-chan any
+	// URL of the emitter
+	url string
+	// the emitter if it runs with a context, nil if it does not
+	runnable RunnableEmitter
+	// publishes the emitter's data events until the context is canceled
+	forward func(ctx context.Context)
+	// options the emitter was added with
+	options *emitterOptions
+	// the encoded retained data event, nil if there is none
+	retained []byte
+	// retained mutex
+	retainedMu *sync.RWMutex
+	// stops the emitter, nil if it is not running
+	cancel context.CancelFunc
+	// what the emitter is doing
+	state EmitterState
+	// the last error reported by the emitter
+	lastErr error
+	// date/time of the last error reported by the emitter
+	lastErrAt time.Time
+	// number of data events emitted
+	events uint64
+	// date/time of the last data event emitted
+	lastEvent time.Time
+	// cancel, state, lastErr, lastErrAt, events and lastEvent mutex
+	stateMu *sync.Mutex
 }
 
-func newEmitterProxy[MsgType any](e Emitter[MsgType]) *emitterProxy {
-	ch := make(chan any)
-
-	go func(ch chan any, ch2 <-chan MsgType) {
-		ch <- <-ch2
-	}(ch, e.DataEvents())
-
-	return &emitterProxy{
+func newEmitterProxy[MsgType any](e Emitter[MsgType], url string, options []EmitterOption) *emitterProxy {
+	p := &emitterProxy{
 		encoderProxy: NewEncoderProxy[MsgType](),
-		dataChannel: func() <-chan any {
-			return ch
-		},
+		url:          url,
+		options:      newEmitterOptions(options),
+		retainedMu:   &sync.RWMutex{},
+		state:        EmitterStopped,
+		stateMu:      &sync.Mutex{},
 	}
+
+	if r, ok := e.(RunnableEmitter); ok {
+		p.runnable = r
+	}
+
+	return p
 }
@@ -0,0 +1,103 @@
+package wts
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// retain stores the payload as the emitter's retained data event.
+func (p *emitterProxy) retain(payload *EventPayload[any]) {
+	retained := *payload
+	retained.Retained = true
+
+	content, err := p.Encode(&retained)
+	if err != nil {
+		log.Err(err).Msg("could not encode retained data event")
+		return
+	}
+
+	p.retainedMu.Lock()
+	p.retained = content
+	p.retainedMu.Unlock()
+}
+
+// handleTopic serves the retained data events of the node's emitters,
+// and passes all other requests to the publisher.
+func (n *Node) handleTopic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		n.Publisher.ServeHTTP(w, r)
+		return
+	}
+
+	eventURL := n.baseURL + "/" + strings.Trim(r.URL.Path, "/")
+	entityURL, eventType, err := ParseEventURL(eventURL)
+	if err != nil || eventType != Data {
+		n.Publisher.ServeHTTP(w, r)
+		return
+	}
+
+	n.emittersMu.RLock()
+	emitter, exists := n.emitters[entityURL]
+	n.emittersMu.RUnlock()
+
+	if !exists || !emitter.options.retain {
+		n.Publisher.ServeHTTP(w, r)
+		return
+	}
+
+	emitter.retainedMu.RLock()
+	content := emitter.retained
+	emitter.retainedMu.RUnlock()
+
+	if content == nil {
+		n.Publisher.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Link", linkHeader(eventURL, n.HubURLs()...))
+	w.Header().Set("Content-Type", PayloadContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// fetchRetained gets the retained data event of a topic, and handles it
+// as if it was received from the subscription.
+//
+// Only events marked as retained are handled, as publishers
+// also serve the last data event of emitters that do not retain it.
+func (n *Node) fetchRetained(topic string) {
+	_, eventType, err := ParseEventURL(topic)
+	if err != nil || eventType != Data {
+		return
+	}
+
+	resp, err := http.Get(topic)
+	if err != nil {
+		log.Err(err).
+			Str("topic", topic).
+			Msg("could not fetch retained data event")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != PayloadContentType {
+		return
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Err(err).
+			Str("topic", topic).
+			Msg("could not read retained data event")
+		return
+	}
+
+	envelope, err := DecodeMessage[any](content)
+	if err != nil || !envelope.Retained {
+		return
+	}
+
+	n.handleEvent(topic, PayloadContentType, bytes.NewReader(content))
+}
@@ -0,0 +1,302 @@
+package wts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var (
+	// Returned by the function of a PollingEmitter to emit nothing, without it being an error.
+	ErrNoData = errors.New("no data to emit")
+)
+
+// ParseFunc parses raw data from a data source into a message.
+type ParseFunc[MsgType any] func(raw []byte) (MsgType, error)
+
+// parseJSON parses raw data as JSON.
+func parseJSON[MsgType any](raw []byte) (MsgType, error) {
+	var msg MsgType
+	err := json.Unmarshal(raw, &msg)
+	return msg, err
+}
+
+// errorReports passes errors reported by an emitter to the node.
+type errorReports chan error
+
+// report reports an error, dropping it if earlier errors were not received yet.
+func (r errorReports) report(err error) {
+	select {
+	case r <- err:
+	default:
+	}
+}
+
+// PollingEmitter is an emitter that emits the result
+// of calling a function every interval while running.
+// It must be created with NewPollingEmitter.
+type PollingEmitter[MsgType any] struct {
+	EmitterName string
+	// How often the function is called.
+	Interval time.Duration
+	// Returns the data event to emit. Nothing is emitted if it returns an error.
+	PollFunc func() (MsgType, error)
+	// channel the results are passed to
+	ch chan MsgType
+	// errors returned by the function
+	errs errorReports
+}
+
+// Name returns a human-readable constant name for this emitter.
+func (e *PollingEmitter[MsgType]) Name() string {
+	return e.EmitterName
+}
+
+// DataEvents returns a channel that passes dataEvents to be published.
+func (e *PollingEmitter[MsgType]) DataEvents() <-chan MsgType {
+	return e.ch
+}
+
+// Errors returns a channel that passes errors returned by the function.
+func (e *PollingEmitter[MsgType]) Errors() <-chan error {
+	return e.errs
+}
+
+// Run calls the function every interval, starting immediately,
+// until the context is canceled.
+func (e *PollingEmitter[MsgType]) Run(ctx context.Context) error {
+	t := time.NewTicker(e.Interval)
+	defer t.Stop()
+
+	for {
+		msg, err := e.PollFunc()
+		if errors.Is(err, ErrNoData) {
+			// nothing to emit
+		} else if err != nil {
+			e.errs.report(err)
+		} else {
+			select {
+			case e.ch <- msg:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NewPollingEmitter creates an emitter that calls poll every interval,
+// and emits the result. The interval must be positive.
+func NewPollingEmitter[MsgType any](
+	name string,
+	interval time.Duration,
+	poll func() (MsgType, error),
+) (Emitter[MsgType], error) {
+	e, err := newPollingEmitter(name, interval, poll)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func newPollingEmitter[MsgType any](
+	name string,
+	interval time.Duration,
+	poll func() (MsgType, error),
+) (*PollingEmitter[MsgType], error) {
+	if interval <= 0 {
+		return nil, errors.New("polling interval must be positive")
+	}
+
+	return &PollingEmitter[MsgType]{
+		EmitterName: name,
+		Interval:    interval,
+		PollFunc:    poll,
+		ch:          make(chan MsgType),
+		errs:        make(errorReports, 1),
+	}, nil
+}
+
+// FileWatchEmitter is an emitter that emits the content
+// of a file every time it changes.
+// It must be created with NewFileWatchEmitter.
+type FileWatchEmitter[MsgType any] struct {
+	*PollingEmitter[MsgType]
+	// The file that is watched.
+	Path string
+	// Parses the content of the file.
+	ParseFunc ParseFunc[MsgType]
+	// content of the file when it was last read
+	last []byte
+}
+
+// read reads the file, and parses it if it changed since the last read.
+func (e *FileWatchEmitter[MsgType]) read() (MsgType, error) {
+	content, err := os.ReadFile(e.Path)
+	if err != nil {
+		return *new(MsgType), err
+	}
+
+	if e.last != nil && bytes.Equal(content, e.last) {
+		return *new(MsgType), ErrNoData
+	}
+
+	e.last = content
+	return e.ParseFunc(content)
+}
+
+// NewFileWatchEmitter creates an emitter that checks the file at path for
+// changes every interval, and emits its content once it does. The content is
+// also emitted when it is first read. The interval must be positive.
+//
+// The content is parsed with parse, or as JSON if parse is nil.
+func NewFileWatchEmitter[MsgType any](
+	name string,
+	path string,
+	interval time.Duration,
+	parse ParseFunc[MsgType],
+) (Emitter[MsgType], error) {
+	if parse == nil {
+		parse = parseJSON[MsgType]
+	}
+
+	e := &FileWatchEmitter[MsgType]{
+		Path:      path,
+		ParseFunc: parse,
+	}
+
+	polling, err := newPollingEmitter(name, interval, e.read)
+	if err != nil {
+		return nil, err
+	}
+
+	e.PollingEmitter = polling
+	return e, nil
+}
+
+// maximum length of a line of output from a CommandEmitter
+const maxCommandLine = 1 << 20
+
+// CommandEmitter is an emitter that runs a command while running,
+// and emits every line it writes to its standard output.
+// It must be created with NewCommandEmitter.
+type CommandEmitter[MsgType any] struct {
+	EmitterName string
+	// The command to run.
+	Command string
+	// The arguments passed to the command.
+	Args []string
+	// Parses each line of output.
+	ParseFunc ParseFunc[MsgType]
+	// channel the parsed lines are passed to
+	ch chan MsgType
+	// lines that could not be parsed
+	errs errorReports
+}
+
+// Name returns a human-readable constant name for this emitter.
+func (e *CommandEmitter[MsgType]) Name() string {
+	return e.EmitterName
+}
+
+// DataEvents returns a channel that passes dataEvents to be published.
+func (e *CommandEmitter[MsgType]) DataEvents() <-chan MsgType {
+	return e.ch
+}
+
+// Errors returns a channel that passes errors from parsing and reading lines.
+func (e *CommandEmitter[MsgType]) Errors() <-chan error {
+	return e.errs
+}
+
+// Run runs the command until it exits or the context is canceled,
+// and emits every line it writes to its standard output.
+//
+// Lines that can not be parsed are skipped. If the output can not be read,
+// such as when a line is longer than 1 MiB, the command is killed.
+func (e *CommandEmitter[MsgType]) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, maxCommandLine)
+	for scanner.Scan() {
+		msg, err := e.ParseFunc(scanner.Bytes())
+		if err != nil {
+			e.errs.report(err)
+			continue
+		}
+
+		select {
+		case e.ch <- msg:
+		case <-ctx.Done():
+		}
+	}
+
+	scanErr := scanner.Err()
+	if scanErr != nil {
+		// nothing reads the output anymore, so the command could block writing it
+		e.errs.report(fmt.Errorf("reading output of %s: %w", e.Command, scanErr))
+		cancel()
+	}
+
+	err = cmd.Wait()
+	if scanErr != nil {
+		return scanErr
+	}
+
+	if ctx.Err() != nil {
+		// killed when stopped
+		return nil
+	}
+
+	return err
+}
+
+// NewCommandEmitter creates an emitter that runs the command with the arguments
+// while it is running, and emits every line it writes to its standard output.
+//
+// Lines are parsed with parse, or as JSON if parse is nil.
+func NewCommandEmitter[MsgType any](
+	name string,
+	parse ParseFunc[MsgType],
+	command string,
+	args ...string,
+) Emitter[MsgType] {
+	if parse == nil {
+		parse = parseJSON[MsgType]
+	}
+
+	return &CommandEmitter[MsgType]{
+		EmitterName: name,
+		Command:     command,
+		Args:        args,
+		ParseFunc:   parse,
+		ch:          make(chan MsgType),
+		errs:        make(errorReports, 1),
+	}
+}
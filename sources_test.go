@@ -0,0 +1,61 @@
+package wts
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestNewPollingEmitterInterval(t *testing.T) {
+	poll := func() (int, error) { return 1, nil }
+
+	tests := []struct {
+		interval time.Duration
+		wantErr  bool
+	}{
+		{-time.Second, true},
+		{0, true},
+		{time.Second, false},
+	}
+
+	for _, test := range tests {
+		_, err := NewPollingEmitter("poll", test.interval, poll)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewPollingEmitter with interval %v: err = %v, want error %v", test.interval, err, test.wantErr)
+		}
+
+		_, err = NewFileWatchEmitter[int]("file", "data.json", test.interval, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewFileWatchEmitter with interval %v: err = %v, want error %v", test.interval, err, test.wantErr)
+		}
+	}
+}
+
+func TestCommandEmitterLongLine(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// a line longer than maxCommandLine, followed by output that is never read
+	script := "head -c 2000000 /dev/zero | tr '\\0' a; echo; yes"
+	e := NewCommandEmitter[string]("cmd", nil, "sh", "-c", script).(*CommandEmitter[string])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := e.Run(ctx)
+	if err == nil {
+		t.Fatal("Run returned no error for a line that is too long")
+	}
+
+	if ctx.Err() != nil {
+		t.Fatal("Run did not return until the context timed out")
+	}
+
+	select {
+	case <-e.Errors():
+	default:
+		t.Error("the read error was not reported")
+	}
+}
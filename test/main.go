@@ -112,7 +112,7 @@ func main() {
 		panic(err)
 	}
 
-	broadcastEventOnNode2, err := wts.AddActorHook[ActorMsg](
+	requestHookOnNode2, err := wts.AddActorHook[ActorMsg](
 		node2, "http://localhost:4044/test",
 		nil, nil,
 	)
@@ -120,7 +120,7 @@ func main() {
 		panic(err)
 	}
 
-	broadcastDataOnNode2, err := wts.AddEmitterHook[EmitterMsg](
+	dataHookOnNode2, err := wts.AddEmitterHook[EmitterMsg](
 		node2, "http://localhost:4044/test",
 		nil,
 	)
@@ -139,7 +139,7 @@ func main() {
 
 	time.Sleep(time.Second)
 
-	err = broadcastEventOnNode2(ActorMsg{
+	err = requestHookOnNode2.Broadcast(ActorMsg{
 		Sound: "xd",
 	})
 	if err != nil {
@@ -148,12 +148,12 @@ func main() {
 
 	time.Sleep(time.Second / 30)
 
-	err = broadcastEventOnNode2(ActorMsg{Sound: "xd"})
+	err = requestHookOnNode2.Broadcast(ActorMsg{Sound: "xd"})
 	if err != nil {
 		panic(err)
 	}
 
-	err = broadcastDataOnNode2(EmitterMsg{
+	err = dataHookOnNode2.Broadcast(EmitterMsg{
 		Xd: 90999,
 	})
 	if err != nil {
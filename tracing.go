@@ -0,0 +1,215 @@
+package wts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SpanKind is what a span describes.
+type SpanKind string
+
+const (
+	// A span for an event published by a node.
+	SpanPublish SpanKind = "publish"
+	// A span for a node handling an event it received.
+	SpanHandle SpanKind = "handle"
+)
+
+// Span is a step in the causal chain of events, such as an emitter's data event
+// triggering a rule that requests an action.
+type Span struct {
+	// ID of the trace the span belongs to, as 32 hex characters.
+	TraceID string `json:"traceID"`
+	// ID of the span, as 16 hex characters.
+	SpanID string `json:"spanID"`
+	// ID of the span that caused this span, empty if there is none.
+	ParentSpanID string `json:"parentSpanID,omitempty"`
+	// What the span describes.
+	Kind SpanKind `json:"kind"`
+	// The event URL of the event.
+	EventURL string `json:"eventURL"`
+	// The ID of the event.
+	EventID string `json:"eventID"`
+	// The base URL of the node that recorded the span.
+	Node string `json:"node"`
+	// When the span started.
+	Start time.Time `json:"start"`
+	// When the span ended.
+	End time.Time `json:"end"`
+}
+
+// SpanExporter is called for every span a node records.
+type SpanExporter func(span Span)
+
+// WithSpanExporter sets a function to be called for every span the node
+// records, to reconstruct the causal chain of events.
+//
+// Events are linked to the event they were caused by when broadcast with
+// a context from ContextWithEvent or EventPayload.Context, and executed and
+// expired events of actors are linked to their request.
+func WithSpanExporter(exporter SpanExporter) NodeOption {
+	return func(n *Node) {
+		n.spanExporter = exporter
+	}
+}
+
+// LogSpan is a SpanExporter that logs spans.
+func LogSpan(span Span) {
+	log.Info().
+		Str("traceID", span.TraceID).
+		Str("spanID", span.SpanID).
+		Str("parentSpanID", span.ParentSpanID).
+		Str("kind", string(span.Kind)).
+		Str("eventURL", span.EventURL).
+		Str("eventID", span.EventID).
+		Str("node", span.Node).
+		Dur("duration", span.End.Sub(span.Start)).
+		Msg("span")
+}
+
+// traceContext identifies a span in a trace.
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// randomHex returns n random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// childOf returns a new span in the same trace as parent,
+// or in a new trace if parent is zero.
+func childOf(parent traceContext) traceContext {
+	traceID := parent.traceID
+	if traceID == "" {
+		traceID = randomHex(16)
+	}
+
+	return traceContext{traceID: traceID, spanID: randomHex(8)}
+}
+
+// String returns the span as a W3C traceparent.
+func (t traceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-01", t.traceID, t.spanID)
+}
+
+// parseTraceParent parses a W3C traceparent. The zero value is returned if it is invalid.
+func parseTraceParent(traceParent string) traceContext {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceContext{}
+	}
+
+	return traceContext{traceID: parts[1], spanID: parts[2]}
+}
+
+// TraceID returns the ID of the trace the event belongs to, empty if there is none.
+func (e *EventPayload[MsgType]) TraceID() string {
+	return parseTraceParent(e.TraceParent).traceID
+}
+
+// SpanID returns the ID of the event's span, empty if there is none.
+func (e *EventPayload[MsgType]) SpanID() string {
+	return parseTraceParent(e.TraceParent).spanID
+}
+
+// traceParentKey is the context key of a traceparent.
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a copy of ctx carrying a W3C traceparent.
+// Events broadcast with the context are linked to the span it identifies.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// ContextWithEvent returns a copy of ctx that links events broadcast with it
+// to e, such as the event being handled by a hook. Events are linked to the
+// span of the node handling e while it is handled.
+func ContextWithEvent[MsgType any](ctx context.Context, e *EventPayload[MsgType]) context.Context {
+	if e.handleSpan != "" {
+		return ContextWithTraceParent(ctx, e.handleSpan)
+	}
+
+	return ContextWithTraceParent(ctx, e.TraceParent)
+}
+
+// Context returns a context that links events broadcast with it to the event,
+// the same as ContextWithEvent(context.Background(), e). Callbacks of hooks
+// and actors pass it to BroadcastContext to continue the trace.
+func (e *EventPayload[MsgType]) Context() context.Context {
+	return ContextWithEvent(context.Background(), e)
+}
+
+// traceParentFrom returns the span carried by ctx, which is zero if there is none.
+func traceParentFrom(ctx context.Context) traceContext {
+	traceParent, _ := ctx.Value(traceParentKey{}).(string)
+	return parseTraceParent(traceParent)
+}
+
+// startHandleSpan starts a span for handling an event, and returns its traceparent.
+// The returned function ends it.
+//
+// Without an exporter no span is recorded, and the event's own traceparent is returned.
+func (n *Node) startHandleSpan(eventURL string, envelope *EventPayload[json.RawMessage]) (traceParent string, end func()) {
+	if n.spanExporter == nil {
+		return envelope.TraceParent, func() {}
+	}
+
+	parent := parseTraceParent(envelope.TraceParent)
+	span := childOf(parent)
+	start := time.Now()
+
+	return span.String(), func() {
+		n.exportSpan(Span{
+			TraceID:      span.traceID,
+			SpanID:       span.spanID,
+			ParentSpanID: parent.spanID,
+			Kind:         SpanHandle,
+			EventURL:     eventURL,
+			EventID:      envelope.ID,
+			Start:        start,
+			End:          time.Now(),
+		})
+	}
+}
+
+// tracePayload sets the traceparent of a new payload, linking it to the span
+// carried by ctx, if any.
+func (n *Node) tracePayload(ctx context.Context, eventURL string, payload *EventPayload[any]) {
+	parent := traceParentFrom(ctx)
+	span := childOf(parent)
+	payload.TraceParent = span.String()
+
+	if n.spanExporter == nil {
+		return
+	}
+
+	n.exportSpan(Span{
+		TraceID:      span.traceID,
+		SpanID:       span.spanID,
+		ParentSpanID: parent.spanID,
+		Kind:         SpanPublish,
+		EventURL:     eventURL,
+		EventID:      payload.ID,
+		Start:        payload.DateSent,
+		End:          payload.DateSent,
+	})
+}
+
+// exportSpan passes a span recorded by the node to the exporter, if there is one.
+func (n *Node) exportSpan(span Span) {
+	if n.spanExporter == nil {
+		return
+	}
+
+	span.Node = n.baseURL
+	n.spanExporter(span)
+}
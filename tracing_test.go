@@ -0,0 +1,103 @@
+package wts
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newTracedNode creates a node that records the spans it exports.
+func newTracedNode() (*Node, *[]Span) {
+	var spans []Span
+	n := NewNode("http://localhost:9101/", "http://localhost:9100/",
+		WithSpanExporter(func(span Span) {
+			spans = append(spans, span)
+		}),
+	)
+
+	return n, &spans
+}
+
+// spansOfKind returns the spans of a kind.
+func spansOfKind(spans []Span, kind SpanKind) []Span {
+	var found []Span
+	for _, span := range spans {
+		if span.Kind == kind {
+			found = append(found, span)
+		}
+	}
+
+	return found
+}
+
+// encodeTraced encodes an event with a traceparent.
+func encodeTraced(t *testing.T, data string, eventType EventType, traceParent string) []byte {
+	content, err := EncodePayload(&EventPayload[string]{
+		ID:          "event",
+		Data:        data,
+		EventType:   eventType,
+		Sender:      "http://localhost:9102/",
+		TraceParent: traceParent,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content
+}
+
+const testTraceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+func TestHookContextIsHandleSpan(t *testing.T) {
+	n, spans := newTracedNode()
+
+	var got traceContext
+	_, err := AddEmitterHook(n, "http://localhost:9102/temp", func(eventURL string, msg *EventPayload[string]) {
+		got = traceParentFrom(msg.Context())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := encodeTraced(t, "warm", Data, testTraceParent)
+	n.handleEvent("http://localhost:9102/temp/data", PayloadContentType, bytes.NewReader(content))
+
+	handled := spansOfKind(*spans, SpanHandle)
+	if len(handled) != 1 {
+		t.Fatalf("got %d handle spans, want 1", len(handled))
+	}
+
+	if handled[0].ParentSpanID != "b7ad6b7169203331" {
+		t.Errorf("handle span parent = %q, want the event's span", handled[0].ParentSpanID)
+	}
+
+	if got.traceID != handled[0].TraceID || got.spanID != handled[0].SpanID {
+		t.Errorf("hook context carries span %+v, want the handle span %+v", got, handled[0])
+	}
+}
+
+func TestExecutedParentIsHandleSpan(t *testing.T) {
+	n, spans := newTracedNode()
+
+	act := func(*EventPayload[string]) bool { return true }
+	err := AddActor(n, NewFuncActor("light", act, act))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := encodeTraced(t, "on", Request, testTraceParent)
+	n.handleEvent("http://localhost:9101/light/request", PayloadContentType, bytes.NewReader(content))
+
+	handled := spansOfKind(*spans, SpanHandle)
+	published := spansOfKind(*spans, SpanPublish)
+	if len(handled) != 1 || len(published) != 1 {
+		t.Fatalf("got %d handle and %d publish spans, want 1 of each", len(handled), len(published))
+	}
+
+	if published[0].EventURL != "http://localhost:9101/light/executed" {
+		t.Fatalf("published %q, want the executed event", published[0].EventURL)
+	}
+
+	if published[0].ParentSpanID != handled[0].SpanID {
+		t.Errorf("executed span parent = %q, want the handle span %q", published[0].ParentSpanID, handled[0].SpanID)
+	}
+}